@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Assertion is a declarative check on a response. A row passes when every
+// assertion holds for its final response.
+type Assertion struct {
+	Description string
+	check       func(resp *httpResponse) error
+}
+
+// Check returns an error describing why the response does not satisfy the assertion
+func (a *Assertion) Check(resp *httpResponse) error {
+	return a.check(resp)
+}
+
+// checkAssertions evaluates every assertion against the response and returns
+// the failure messages
+func checkAssertions(assertions []*Assertion, resp *httpResponse) []string {
+	var failures []string
+	for _, a := range assertions {
+		if err := a.Check(resp); err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	return failures
+}
+
+// parseStatusAssertion parses an expected status specification: a comma
+// separated list of codes ("200,201"), classes ("2xx") and ranges ("200-299")
+func parseStatusAssertion(spec string) (*Assertion, error) {
+	var matchers []func(code int) bool
+
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		switch {
+		case len(field) == 3 && strings.HasSuffix(strings.ToLower(field), "xx"):
+			class, err := strconv.Atoi(field[:1])
+			if err != nil || class < 1 || class > 5 {
+				return nil, fmt.Errorf("invalid status class %q", field)
+			}
+			matchers = append(matchers, func(code int) bool { return code/100 == class })
+		case strings.Contains(field, "-"):
+			lo, hi, _ := strings.Cut(field, "-")
+			low, err1 := strconv.Atoi(strings.TrimSpace(lo))
+			high, err2 := strconv.Atoi(strings.TrimSpace(hi))
+			if err1 != nil || err2 != nil || low > high {
+				return nil, fmt.Errorf("invalid status range %q", field)
+			}
+			matchers = append(matchers, func(code int) bool { return code >= low && code <= high })
+		default:
+			expected, err := strconv.Atoi(field)
+			if err != nil || expected < 100 || expected > 599 {
+				return nil, fmt.Errorf("invalid status code %q", field)
+			}
+			matchers = append(matchers, func(code int) bool { return code == expected })
+		}
+	}
+
+	description := "status " + spec
+	return &Assertion{
+		Description: description,
+		check: func(resp *httpResponse) error {
+			for _, match := range matchers {
+				if match(resp.StatusCode) {
+					return nil
+				}
+			}
+			return fmt.Errorf("expected %s, got %d", description, resp.StatusCode)
+		},
+	}, nil
+}
+
+// parseHeaderAssertion parses an expected header: "Name" requires the header
+// to be present, "Name=value" requires it to equal value
+func parseHeaderAssertion(spec string) (*Assertion, error) {
+	name, value, hasValue := strings.Cut(spec, "=")
+	name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+	value = strings.TrimSpace(value)
+	if name == "" {
+		return nil, fmt.Errorf("invalid header assertion %q", spec)
+	}
+
+	if !hasValue {
+		return &Assertion{
+			Description: "header " + name,
+			check: func(resp *httpResponse) error {
+				if _, ok := resp.Header[name]; !ok {
+					return fmt.Errorf("expected header %s to be present", name)
+				}
+				return nil
+			},
+		}, nil
+	}
+
+	return &Assertion{
+		Description: fmt.Sprintf("header %s=%s", name, value),
+		check: func(resp *httpResponse) error {
+			if _, ok := resp.Header[name]; !ok {
+				return fmt.Errorf("expected header %s to be %q, but it is missing", name, value)
+			}
+			if actual := resp.Header.Get(name); actual != value {
+				return fmt.Errorf("expected header %s to be %q, got %q", name, value, actual)
+			}
+			return nil
+		},
+	}, nil
+}
+
+// bodyContainsAssertion requires the response body to contain substr
+func bodyContainsAssertion(substr string) *Assertion {
+	return &Assertion{
+		Description: fmt.Sprintf("body contains %q", substr),
+		check: func(resp *httpResponse) error {
+			if !bytes.Contains(resp.Body, []byte(substr)) {
+				return fmt.Errorf("expected body to contain %q", substr)
+			}
+			return nil
+		},
+	}
+}
+
+// parseBodyRegexAssertion requires the response body to match a regular expression
+func parseBodyRegexAssertion(expr string) (*Assertion, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid body regex %q: %w", expr, err)
+	}
+
+	return &Assertion{
+		Description: fmt.Sprintf("body matches %q", expr),
+		check: func(resp *httpResponse) error {
+			if !re.Match(resp.Body) {
+				return fmt.Errorf("expected body to match %q", expr)
+			}
+			return nil
+		},
+	}, nil
+}
+
+// parseJSONAssertion parses "path=value", requiring the value at the JSON
+// path to equal value. Values are compared the way -extract outputs them.
+func parseJSONAssertion(spec string) (*Assertion, error) {
+	path, expected, found := strings.Cut(spec, "=")
+	path = strings.TrimSpace(path)
+	if !found || path == "" {
+		return nil, fmt.Errorf("invalid JSON assertion %q: expected path=value", spec)
+	}
+
+	keys, err := parseJSONPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON assertion %q: %w", spec, err)
+	}
+	extraction := &Extraction{Path: path, keys: keys}
+
+	return &Assertion{
+		Description: fmt.Sprintf("JSON %s=%s", path, expected),
+		check: func(resp *httpResponse) error {
+			actual, ok := extraction.Extract(resp.Body)
+			if !ok {
+				return fmt.Errorf("expected JSON %s to be %q, but it is missing", path, expected)
+			}
+			if actual != expected {
+				return fmt.Errorf("expected JSON %s to be %q, got %q", path, expected, actual)
+			}
+			return nil
+		},
+	}, nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestStatusAssertion(t *testing.T) {
+	tests := []struct {
+		spec   string
+		code   int
+		passed bool
+	}{
+		{spec: "200", code: 200, passed: true},
+		{spec: "200", code: 201, passed: false},
+		{spec: "200,201", code: 201, passed: true},
+		{spec: "2xx", code: 204, passed: true},
+		{spec: "2xx", code: 500, passed: false},
+		{spec: "2XX,404", code: 404, passed: true},
+		{spec: "200-299", code: 299, passed: true},
+		{spec: "200-299", code: 300, passed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			a, err := parseStatusAssertion(tt.spec)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			err = a.Check(&httpResponse{StatusCode: tt.code})
+			if tt.passed && err != nil {
+				t.Errorf("Expected %d to pass, got %v", tt.code, err)
+			}
+			if !tt.passed && err == nil {
+				t.Errorf("Expected %d to fail", tt.code)
+			}
+		})
+	}
+
+	for _, spec := range []string{"", "abc", "6xx", "299-200", "1000"} {
+		if _, err := parseStatusAssertion(spec); err == nil {
+			t.Errorf("Expected error for %q", spec)
+		}
+	}
+}
+
+func TestHeaderAssertion(t *testing.T) {
+	resp := &httpResponse{Header: http.Header{"Content-Type": {"application/json"}}}
+
+	tests := []struct {
+		spec   string
+		passed bool
+	}{
+		{spec: "Content-Type", passed: true},
+		{spec: "content-type", passed: true},
+		{spec: "Content-Type=application/json", passed: true},
+		{spec: "Content-Type=text/html", passed: false},
+		{spec: "X-Request-Id", passed: false},
+		{spec: "X-Request-Id=1", passed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			a, err := parseHeaderAssertion(tt.spec)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			err = a.Check(resp)
+			if tt.passed && err != nil {
+				t.Errorf("Expected to pass, got %v", err)
+			}
+			if !tt.passed && err == nil {
+				t.Error("Expected to fail")
+			}
+		})
+	}
+
+	if _, err := parseHeaderAssertion("=value"); err == nil {
+		t.Error("Expected error for missing header name")
+	}
+}
+
+func TestBodyAssertions(t *testing.T) {
+	resp := &httpResponse{Body: []byte(`{"status": "active", "id": 42}`)}
+
+	if err := bodyContainsAssertion(`"active"`).Check(resp); err != nil {
+		t.Errorf("Expected body contains to pass, got %v", err)
+	}
+	if err := bodyContainsAssertion("inactive").Check(resp); err == nil {
+		t.Error("Expected body contains to fail")
+	}
+
+	a, err := parseBodyRegexAssertion(`"id":\s*\d+`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := a.Check(resp); err != nil {
+		t.Errorf("Expected body regex to pass, got %v", err)
+	}
+
+	a, err = parseBodyRegexAssertion(`^\[`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := a.Check(resp); err == nil {
+		t.Error("Expected body regex to fail")
+	}
+
+	if _, err := parseBodyRegexAssertion("("); err == nil {
+		t.Error("Expected error for invalid regex")
+	}
+}
+
+func TestJSONAssertion(t *testing.T) {
+	resp := &httpResponse{Body: []byte(`{"data": {"status": "active", "count": 3}}`)}
+
+	tests := []struct {
+		spec    string
+		passed  bool
+		message string
+	}{
+		{spec: "$.data.status=active", passed: true},
+		{spec: "$.data.count=3", passed: true},
+		{spec: "$.data.status=inactive", passed: false, message: `got "active"`},
+		{spec: "$.data.missing=x", passed: false, message: "missing"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			a, err := parseJSONAssertion(tt.spec)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			err = a.Check(resp)
+			if tt.passed && err != nil {
+				t.Errorf("Expected to pass, got %v", err)
+			}
+			if !tt.passed && (err == nil || !strings.Contains(err.Error(), tt.message)) {
+				t.Errorf("Expected failure containing %q, got %v", tt.message, err)
+			}
+		})
+	}
+
+	for _, spec := range []string{"$.status", "=active", "$.items[x]=1"} {
+		if _, err := parseJSONAssertion(spec); err == nil {
+			t.Errorf("Expected error for %q", spec)
+		}
+	}
+}
+
+func TestCheckAssertions(t *testing.T) {
+	status, _ := parseStatusAssertion("2xx")
+	header, _ := parseHeaderAssertion("X-Request-Id")
+	assertions := []*Assertion{status, header, bodyContainsAssertion("ok")}
+
+	failures := checkAssertions(assertions, &httpResponse{StatusCode: 500, Header: http.Header{}, Body: []byte("ok")})
+	if len(failures) != 2 {
+		t.Fatalf("Expected 2 failures, got %v", failures)
+	}
+	if failures[0] != "expected status 2xx, got 500" {
+		t.Errorf("Unexpected failure message %q", failures[0])
+	}
+
+	if failures := checkAssertions(nil, &httpResponse{StatusCode: 500}); len(failures) != 0 {
+		t.Errorf("Expected no failures without assertions, got %v", failures)
+	}
+}
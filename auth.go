@@ -0,0 +1,200 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Authentication schemes selected by --basic and --digest
+const (
+	authBasic  = "basic"
+	authDigest = "digest"
+)
+
+var envVariablePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces ${NAME} in a credential with the environment variable
+// NAME. Template variables are replaced before the command is parsed, so a
+// CSV column with the same name takes precedence.
+func expandEnv(value string) (string, error) {
+	var missing []string
+	expanded := envVariablePattern.ReplaceAllStringFunc(value, func(match string) string {
+		name := match[2 : len(match)-1]
+		if v, ok := os.LookupEnv(name); ok {
+			return v
+		}
+		missing = append(missing, name)
+		return match
+	})
+
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+// parseUserOption parses the value of -u/--user, "user:password"
+func parseUserOption(value string) (string, string, error) {
+	expanded, err := expandEnv(value)
+	if err != nil {
+		return "", "", err
+	}
+
+	user, password, found := strings.Cut(expanded, ":")
+	if !found {
+		return "", "", fmt.Errorf("password is required: prompting for it is not supported, use user:password")
+	}
+	return user, password, nil
+}
+
+// applyAuth sets the Authorization header for --oauth2-bearer and basic
+// authentication. As in curl, an Authorization header given with -H wins.
+// Digest authentication needs a challenge first and is handled by
+// executeRequest.
+func applyAuth(req *http.Request, creq *curlRequest) {
+	if req.Header.Get("Authorization") != "" {
+		return
+	}
+
+	switch {
+	case creq.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+creq.BearerToken)
+	case creq.hasCredentials() && creq.AuthScheme != authDigest:
+		req.SetBasicAuth(creq.User, creq.Password)
+	}
+}
+
+// digestChallenge holds the parameters of a "WWW-Authenticate: Digest" header
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+}
+
+// findDigestChallenge returns the Digest challenge of a 401 response, or nil
+func findDigestChallenge(header http.Header) *digestChallenge {
+	for _, value := range header.Values("WWW-Authenticate") {
+		scheme, rest, _ := strings.Cut(strings.TrimSpace(value), " ")
+		if !strings.EqualFold(scheme, "Digest") {
+			continue
+		}
+
+		params := parseAuthParams(rest)
+		challenge := &digestChallenge{
+			realm:     params["realm"],
+			nonce:     params["nonce"],
+			opaque:    params["opaque"],
+			algorithm: params["algorithm"],
+		}
+		for _, qop := range strings.Split(params["qop"], ",") {
+			if strings.TrimSpace(qop) == "auth" {
+				challenge.qop = "auth"
+			}
+		}
+		return challenge
+	}
+	return nil
+}
+
+// parseAuthParams parses the comma separated key=value parameters of an
+// authentication challenge, where values may be quoted strings
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		key, rest, found := strings.Cut(s, "=")
+		if !found {
+			return params
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		rest = strings.TrimLeft(rest, " \t")
+
+		var value strings.Builder
+		if strings.HasPrefix(rest, `"`) {
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				value.WriteByte(rest[i])
+			}
+			s = rest[min(i+1, len(rest)):]
+		} else {
+			end := strings.IndexByte(rest, ',')
+			if end < 0 {
+				end = len(rest)
+			}
+			value.WriteString(strings.TrimSpace(rest[:end]))
+			s = rest[end:]
+		}
+		params[key] = value.String()
+	}
+}
+
+// authorization computes the Authorization header answering the challenge
+// (RFC 7616) for the given request
+func (c *digestChallenge) authorization(user, password, method, uri string) (string, error) {
+	algorithm := strings.ToUpper(c.algorithm)
+	if algorithm == "" {
+		algorithm = "MD5"
+	}
+
+	var newHash func() hash.Hash
+	switch strings.TrimSuffix(algorithm, "-SESS") {
+	case "MD5":
+		newHash = md5.New
+	case "SHA-256":
+		newHash = sha256.New
+	default:
+		return "", fmt.Errorf("unsupported digest algorithm %q", c.algorithm)
+	}
+	h := func(s string) string {
+		sum := newHash()
+		sum.Write([]byte(s))
+		return hex.EncodeToString(sum.Sum(nil))
+	}
+
+	cnonce := rand.Text()
+	const nc = "00000001"
+
+	ha1 := h(user + ":" + c.realm + ":" + password)
+	if strings.HasSuffix(algorithm, "-SESS") {
+		ha1 = h(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+	ha2 := h(method + ":" + uri)
+
+	var response string
+	if c.qop == "auth" {
+		response = h(strings.Join([]string{ha1, c.nonce, nc, cnonce, c.qop, ha2}, ":"))
+	} else {
+		response = h(ha1 + ":" + c.nonce + ":" + ha2)
+	}
+
+	fields := []string{
+		fmt.Sprintf(`username="%s"`, escapeQuotes(user)),
+		fmt.Sprintf(`realm="%s"`, escapeQuotes(c.realm)),
+		fmt.Sprintf(`nonce="%s"`, escapeQuotes(c.nonce)),
+		fmt.Sprintf(`uri="%s"`, escapeQuotes(uri)),
+		fmt.Sprintf(`algorithm=%s`, algorithm),
+		fmt.Sprintf(`response="%s"`, response),
+	}
+	if c.opaque != "" {
+		fields = append(fields, fmt.Sprintf(`opaque="%s"`, escapeQuotes(c.opaque)))
+	}
+	if c.qop == "auth" {
+		fields = append(fields, "qop=auth", "nc="+nc, fmt.Sprintf(`cnonce="%s"`, cnonce))
+	}
+
+	return "Digest " + strings.Join(fields, ", "), nil
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseAuthOptions(t *testing.T) {
+	t.Setenv("CURL_BATCH_TEST_PASSWORD", "s3cret")
+	t.Setenv("CURL_BATCH_TEST_TOKEN", "tok-123")
+
+	tests := []struct {
+		name       string
+		command    string
+		user       string
+		password   string
+		authScheme string
+		bearer     string
+		hasError   bool
+	}{
+		{
+			name:     "User and password",
+			command:  `curl -u alice:pa:ss https://api.example.com`,
+			user:     "alice",
+			password: "pa:ss",
+		},
+		{
+			name:     "Password from environment",
+			command:  `curl --user 'alice:${CURL_BATCH_TEST_PASSWORD}' https://api.example.com`,
+			user:     "alice",
+			password: "s3cret",
+		},
+		{
+			name:       "Digest",
+			command:    `curl --digest -u alice:pw https://api.example.com`,
+			user:       "alice",
+			password:   "pw",
+			authScheme: authDigest,
+		},
+		{
+			name:       "Basic",
+			command:    `curl --basic -u alice: https://api.example.com`,
+			user:       "alice",
+			authScheme: authBasic,
+		},
+		{
+			name:    "Bearer token from environment",
+			command: `curl --oauth2-bearer '${CURL_BATCH_TEST_TOKEN}' https://api.example.com`,
+			bearer:  "tok-123",
+		},
+		{
+			name:     "Missing password",
+			command:  `curl -u alice https://api.example.com`,
+			hasError: true,
+		},
+		{
+			name:     "Unset environment variable",
+			command:  `curl -u 'alice:${CURL_BATCH_TEST_UNSET}' https://api.example.com`,
+			hasError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := parseCurlCommand(tt.command)
+
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if req.User != tt.user || req.Password != tt.password {
+				t.Errorf("Expected credentials %q:%q, got %q:%q", tt.user, tt.password, req.User, req.Password)
+			}
+			if req.AuthScheme != tt.authScheme {
+				t.Errorf("Expected auth scheme %q, got %q", tt.authScheme, req.AuthScheme)
+			}
+			if req.BearerToken != tt.bearer {
+				t.Errorf("Expected bearer token %q, got %q", tt.bearer, req.BearerToken)
+			}
+		})
+	}
+}
+
+func TestApplyAuth(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  string
+		expected string
+	}{
+		{
+			name:     "Basic",
+			command:  `curl -u alice:secret https://api.example.com`,
+			expected: "Basic YWxpY2U6c2VjcmV0",
+		},
+		{
+			name:     "Bearer",
+			command:  `curl --oauth2-bearer abc https://api.example.com`,
+			expected: "Bearer abc",
+		},
+		{
+			name:     "Header given with -H wins",
+			command:  `curl -u alice:secret -H "Authorization: Token xyz" https://api.example.com`,
+			expected: "Token xyz",
+		},
+		{
+			name:     "Digest waits for the challenge",
+			command:  `curl --digest -u alice:secret https://api.example.com`,
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := newHTTPRequest(context.Background(), mustParseCurlCommand(t, tt.command))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := req.Header.Get("Authorization"); got != tt.expected {
+				t.Errorf("Expected Authorization %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestDigestAuth(t *testing.T) {
+	const (
+		realm    = "api@example.com"
+		nonce    = "dcd98b7102dd2f0e8b11d0f600bfb0c093"
+		opaque   = "5ccc069c403ebaf9f0171e9517f40e41"
+		user     = "Mufasa"
+		password = "Circle of Life"
+	)
+	md5Hex := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		authorization := r.Header.Get("Authorization")
+		if authorization == "" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest realm="%s", qop="auth,auth-int", nonce="%s", opaque="%s"`, realm, nonce, opaque))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		params := parseAuthParams(strings.TrimPrefix(authorization, "Digest "))
+		ha1 := md5Hex(user + ":" + realm + ":" + password)
+		ha2 := md5Hex(r.Method + ":" + r.URL.RequestURI())
+		expected := md5Hex(strings.Join([]string{ha1, nonce, params["nc"], params["cnonce"], "auth", ha2}, ":"))
+
+		if params["username"] != user || params["uri"] != r.URL.RequestURI() || params["opaque"] != opaque || params["response"] != expected {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	cb := &CurlBatch{}
+	req := mustParseCurlCommand(t, `curl --digest -u "Mufasa:Circle of Life" -d hello `+server.URL+`/dir/index.html?x=1`)
+
+	resp, err := cb.executeRequest(req)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	if string(resp.Body) != "hello" {
+		t.Errorf("Expected the body to be sent again, got %q", resp.Body)
+	}
+	if requests != 2 {
+		t.Errorf("Expected 2 requests, got %d", requests)
+	}
+}
+
+func TestParseAuthParams(t *testing.T) {
+	params := parseAuthParams(`realm="a, \"b\"", nonce=abc, qop="auth"`)
+	expected := map[string]string{"realm": `a, "b"`, "nonce": "abc", "qop": "auth"}
+
+	for key, value := range expected {
+		if params[key] != value {
+			t.Errorf("Expected %s=%q, got %q", key, value, params[key])
+		}
+	}
+}
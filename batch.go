@@ -1,17 +1,80 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// defaultMaxTime is the maximum time of a request unless configured otherwise
+const defaultMaxTime = 30 * time.Second
+
+// reorderWindow is how many rows per worker may be dispatched ahead of the
+// oldest row whose result has not been written yet. It bounds the results
+// held back for the output while a slow row is still running.
+const reorderWindow = 4
+
 // CurlBatch represents a batch of curl requests to be executed
 type CurlBatch struct {
 	CurlTemplate string
+	CSVHeaders   []string
 	CSVData      []map[string]string
 	OutputFile   *os.File
 	SleepMsec    int
+	Concurrency  int
+	RateLimiter  *RateLimiter
+	Retry        *RetryPolicy
+	Checkpoint   *Checkpoint
+	Format       string
+	Columns      []string
+	Extractions  []*Extraction
+	Assertions   []*Assertion
+	Proxy        *url.URL // overrides -x/--proxy of the template
+	NoProxy      string   // overrides --noproxy of the template
+
+	// Defaults for requests whose template has no --connect-timeout or
+	// -m/--max-time; zero means no limit
+	ConnectTimeout time.Duration
+	MaxTime        time.Duration
+
+	CookieSession string // cookieSessionRow or cookieSessionShared
+
+	cookieMu    sync.Mutex
+	sharedJar   *CookieJar            // created by the first row with -cookie-session shared
+	cookieFiles map[string]cookieFile // -b files as they were before the first row ran
+	savedJars   map[string]*CookieJar // by -c file, the merged jars of -cookie-session row
+
+	Pool PoolOptions
+
+	clientMu    sync.Mutex
+	clients     map[string]*http.Client // by transportKey
+	newConns    atomic.Int64
+	reusedConns atomic.Int64
+}
+
+// rowResult holds the outcome of executing the request for a single CSV row
+type rowResult struct {
+	Index     int
+	Row       map[string]string
+	Command   string
+	Request   *curlRequest
+	Response  *httpResponse
+	Err       error
+	Attempts  []attemptResult
+	Extracted map[string]string
+	Failures  []string // failed assertions
+	Skipped   bool
+}
+
+// failed reports whether the row failed, either because the request could
+// not be completed or because an assertion did not hold
+func (r *rowResult) failed() bool {
+	return r.Err != nil || len(r.Failures) > 0
 }
 
 // NewCurlBatch creates a new CurlBatch instance
@@ -21,7 +84,7 @@ func NewCurlBatch(curlFile, csvFile, outputFile string, sleepMsec int) (*CurlBat
 		return nil, fmt.Errorf("failed to read curl template: %w", err)
 	}
 
-	csvData, err := readCSVData(csvFile)
+	csvHeaders, csvData, err := readCSVFile(csvFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read CSV data: %w", err)
 	}
@@ -32,40 +95,224 @@ func NewCurlBatch(curlFile, csvFile, outputFile string, sleepMsec int) (*CurlBat
 	}
 
 	return &CurlBatch{
-		CurlTemplate: curlTemplate,
-		CSVData:      csvData,
-		OutputFile:   output,
-		SleepMsec:    sleepMsec,
+		CurlTemplate:  curlTemplate,
+		CSVHeaders:    csvHeaders,
+		CSVData:       csvData,
+		OutputFile:    output,
+		SleepMsec:     sleepMsec,
+		Concurrency:   1,
+		MaxTime:       defaultMaxTime,
+		CookieSession: cookieSessionRow,
+		Pool:          PoolOptions{IdleTimeout: defaultIdleTimeout},
 	}, nil
 }
 
-// Run executes all curl requests in the batch
-func (cb *CurlBatch) Run() error {
+// Run executes all curl requests in the batch.
+// Requests are executed by Concurrency workers, but results are always
+// written to the output file in CSV order.
+//
+// When ctx is cancelled no new rows are started; requests already in flight
+// are allowed to finish and their results are written before Run returns.
+func (cb *CurlBatch) Run(ctx context.Context) (*Summary, error) {
 	defer cb.OutputFile.Close()
+	defer cb.closeIdleConnections()
+	if cb.Checkpoint != nil {
+		defer cb.Checkpoint.Close()
+	}
+
+	output, err := cb.newResultWriter()
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	rows := cb.pendingRows()
+	summary := &Summary{
+		Total:   len(cb.CSVData),
+		Skipped: len(cb.CSVData) - len(rows),
+	}
+
+	// Read the -b files before any row can overwrite them with -c
+	cb.loadCookieFiles(rows)
+
+	workers := cb.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(rows) {
+		workers = len(rows)
+	}
+
+	jobs := make(chan int)
+	results := make(chan *rowResult)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cb.worker(ctx, jobs, results)
+		}()
+	}
+
+	// A slot of window is taken for every dispatched row and given back
+	// when its result is written
+	window := make(chan struct{}, max(workers, 1)*reorderWindow)
+
+	// Rows are dispatched in order, so the rows that produce a result are
+	// always a prefix of rows even when dispatching stops early.
+	go func() {
+		defer close(jobs)
+		for _, i := range rows {
+			select {
+			case window <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
 
-	for i, row := range cb.CSVData {
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// Results arrive in completion order; hold them back until every
+	// preceding row has been written so the output stays in CSV order.
+	pending := make(map[int]*rowResult)
+	next := 0
+	completed := 0
+	var writeErr error
+
+	for result := range results {
+		if !result.Skipped {
+			completed++
+			fmt.Printf("Completed request %d/%d\n", completed, len(rows))
+		}
+
+		pending[result.Index] = result
+		for next < len(rows) {
+			r, ok := pending[rows[next]]
+			if !ok {
+				break
+			}
+			delete(pending, rows[next])
+			next++
+			<-window
+
+			summary.add(r)
+			if r.Skipped {
+				continue
+			}
+
+			if writeErr == nil {
+				writeErr = output.WriteResult(r)
+			}
+			// Only checkpoint rows whose output was written
+			if writeErr == nil && cb.Checkpoint != nil {
+				if err := cb.Checkpoint.Record(r); err != nil {
+					writeErr = fmt.Errorf("checkpoint: %w", err)
+				}
+			}
+		}
+	}
+
+	// Rows that were never dispatched because of an interruption
+	summary.Skipped += len(rows) - next
+	summary.Interrupted = ctx.Err() != nil
+	summary.Elapsed = time.Since(start)
+	summary.NewConnections = int(cb.newConns.Load())
+	summary.ReusedConnections = int(cb.reusedConns.Load())
+
+	if writeErr != nil {
+		return summary, fmt.Errorf("failed to write output: %w", writeErr)
+	}
+
+	return summary, nil
+}
+
+// pendingRows returns the indices of the CSV rows that still have to be
+// executed, skipping rows the checkpoint records as completed
+func (cb *CurlBatch) pendingRows() []int {
+	var rows []int
+	for i := range cb.CSVData {
+		if cb.Checkpoint != nil && cb.Checkpoint.Completed(i) {
+			continue
+		}
+		rows = append(rows, i)
+	}
+	return rows
+}
+
+// worker executes requests for the row indices received on jobs.
+// When SleepMsec is set, each worker pauses after every request it
+// executes, so with a single worker this is the pause between requests.
+func (cb *CurlBatch) worker(ctx context.Context, jobs <-chan int, results chan<- *rowResult) {
+	for i := range jobs {
+		row := cb.CSVData[i]
 		curlCommand := cb.replaceTemplate(cb.CurlTemplate, row)
 
-		fmt.Fprintf(cb.OutputFile, "=== Request %d ===\n", i+1)
-		fmt.Fprintf(cb.OutputFile, "Command: %s\n", curlCommand)
-		fmt.Fprintf(cb.OutputFile, "Data: %+v\n", row)
+		result := &rowResult{Index: i, Row: row, Command: curlCommand}
 
-		result, err := cb.executeRequest(curlCommand)
+		req, err := parseCurlCommand(curlCommand)
 		if err != nil {
-			fmt.Fprintf(cb.OutputFile, "Error: %s\n", err)
-		} else {
-			fmt.Fprintf(cb.OutputFile, "Result:\n%s\n", result)
+			// The command itself is broken, so there is nothing to attempt
+			result.Err = err
+			results <- result
+			continue
 		}
+		result.Request = req
 
-		fmt.Fprintf(cb.OutputFile, "\n")
+		req.Jar, err = cb.cookieJar(req)
+		if err != nil {
+			result.Err = err
+			results <- result
+			continue
+		}
 
-		fmt.Printf("Completed request %d/%d\n", i+1, len(cb.CSVData))
+		attempts := cb.executeWithRetry(ctx, req)
+		if len(attempts) == 0 {
+			// Interrupted before the first attempt was started
+			result.Skipped = true
+			results <- result
+			continue
+		}
+
+		final := attempts[len(attempts)-1]
+		result.Response = final.Response
+		result.Err = final.Err
+		result.Attempts = attempts
+		result.Extracted = extractValues(cb.Extractions, final.Response)
+		if final.Err == nil {
+			result.Failures = checkAssertions(cb.Assertions, final.Response)
+		}
+		if err := cb.saveCookieJar(req); err != nil && result.Err == nil {
+			result.Err = err
+		}
+		results <- result
 
 		// Sleep between requests if specified
 		if cb.SleepMsec > 0 && i < len(cb.CSVData)-1 {
-			time.Sleep(time.Duration(cb.SleepMsec) * time.Millisecond)
+			sleepContext(ctx, time.Duration(cb.SleepMsec)*time.Millisecond)
 		}
 	}
+}
+
+// sleepContext pauses for d or until ctx is cancelled, whichever comes first.
+// It reports whether the full duration elapsed.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
 
-	return nil
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
 }
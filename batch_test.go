@@ -1,9 +1,17 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestNewCurlBatch(t *testing.T) {
@@ -187,3 +195,200 @@ func TestNewCurlBatchDefaultSleep(t *testing.T) {
 		t.Errorf("Expected sleep 0, got %d", batch.SleepMsec)
 	}
 }
+
+func TestRunConcurrentKeepsCSVOrder(t *testing.T) {
+	// Later rows respond faster so they complete before earlier ones
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ := strconv.Atoi(r.URL.Query().Get("id"))
+		time.Sleep(time.Duration(10-id) * 10 * time.Millisecond)
+		fmt.Fprintf(w, "id=%d", id)
+	}))
+	defer server.Close()
+
+	tmpDir, err := os.MkdirTemp("", "curl_batch_test_*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	curlFile := filepath.Join(tmpDir, "curl.txt")
+	err = os.WriteFile(curlFile, []byte(`curl "`+server.URL+`/?id=${ID}"`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create curl file: %v", err)
+	}
+
+	csvFile := filepath.Join(tmpDir, "data.csv")
+	csvContent := "ID\n"
+	for i := 0; i < 10; i++ {
+		csvContent += strconv.Itoa(i) + "\n"
+	}
+	err = os.WriteFile(csvFile, []byte(csvContent), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create CSV file: %v", err)
+	}
+
+	outputFile := filepath.Join(tmpDir, "output.txt")
+	batch, err := NewCurlBatch(curlFile, csvFile, outputFile, 0)
+	if err != nil {
+		t.Fatalf("NewCurlBatch failed: %v", err)
+	}
+	batch.Concurrency = 5
+
+	if _, err := batch.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+
+	blocks := strings.Split(strings.TrimSpace(string(content)), "\n\n")
+	if len(blocks) != 10 {
+		t.Fatalf("Expected 10 request blocks, got %d:\n%s", len(blocks), content)
+	}
+	for i, block := range blocks {
+		if !strings.HasPrefix(block, fmt.Sprintf("=== Request %d ===\n", i+1)) {
+			t.Errorf("Block %d has wrong header: %q", i, block)
+		}
+		if !strings.Contains(block, fmt.Sprintf("Body: id=%d", i)) {
+			t.Errorf("Block %d has wrong body: %q", i, block)
+		}
+	}
+}
+
+func TestRunSlowRowLimitsDispatch(t *testing.T) {
+	// Row 1 is slow; the rows requested while it runs are held back for
+	// the output, so only a window of them may be dispatched
+	var slowDone atomic.Bool
+	var whileSlow atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Query().Get("id")
+		if id == "0" {
+			time.Sleep(200 * time.Millisecond)
+			slowDone.Store(true)
+		} else if !slowDone.Load() {
+			whileSlow.Add(1)
+		}
+		fmt.Fprintf(w, "id=%s", id)
+	}))
+	defer server.Close()
+
+	tmpDir, err := os.MkdirTemp("", "curl_batch_test_*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	curlFile := filepath.Join(tmpDir, "curl.txt")
+	err = os.WriteFile(curlFile, []byte(`curl "`+server.URL+`/?id=${ID}"`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create curl file: %v", err)
+	}
+
+	csvFile := filepath.Join(tmpDir, "data.csv")
+	csvContent := "ID\n"
+	for i := 0; i < 50; i++ {
+		csvContent += strconv.Itoa(i) + "\n"
+	}
+	err = os.WriteFile(csvFile, []byte(csvContent), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create CSV file: %v", err)
+	}
+
+	outputFile := filepath.Join(tmpDir, "output.txt")
+	batch, err := NewCurlBatch(curlFile, csvFile, outputFile, 0)
+	if err != nil {
+		t.Fatalf("NewCurlBatch failed: %v", err)
+	}
+	batch.Concurrency = 2
+
+	summary, err := batch.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if summary.Succeeded != 50 {
+		t.Errorf("Expected all 50 rows to succeed, got %+v", summary)
+	}
+
+	// The window includes the slow row itself
+	if limit := int32(batch.Concurrency*reorderWindow - 1); whileSlow.Load() > limit {
+		t.Errorf("Expected at most %d rows to run during the slow row, got %d", limit, whileSlow.Load())
+	}
+
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+	blocks := strings.Split(strings.TrimSpace(string(content)), "\n\n")
+	if len(blocks) != 50 {
+		t.Fatalf("Expected 50 request blocks, got %d", len(blocks))
+	}
+	for i, block := range blocks {
+		if !strings.Contains(block+"\n", fmt.Sprintf("Body: id=%d\n", i)) {
+			t.Errorf("Block %d has wrong body: %q", i, block)
+		}
+	}
+}
+
+func TestRunInterruptedFinishesInFlightRequests(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The first request cancels the run while it is still in flight
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("id") == "1" {
+			cancel()
+			time.Sleep(50 * time.Millisecond)
+		}
+		fmt.Fprintf(w, "id=%s", r.URL.Query().Get("id"))
+	}))
+	defer server.Close()
+
+	tmpDir, err := os.MkdirTemp("", "curl_batch_test_*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	curlFile := filepath.Join(tmpDir, "curl.txt")
+	err = os.WriteFile(curlFile, []byte(`curl "`+server.URL+`/?id=${ID}"`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create curl file: %v", err)
+	}
+
+	csvFile := filepath.Join(tmpDir, "data.csv")
+	err = os.WriteFile(csvFile, []byte("ID\n1\n2\n3\n4"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create CSV file: %v", err)
+	}
+
+	outputFile := filepath.Join(tmpDir, "output.txt")
+	batch, err := NewCurlBatch(curlFile, csvFile, outputFile, 0)
+	if err != nil {
+		t.Fatalf("NewCurlBatch failed: %v", err)
+	}
+
+	summary, err := batch.Run(ctx)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if !summary.Interrupted {
+		t.Error("Expected summary to report an interruption")
+	}
+	if summary.Total != 4 || summary.Succeeded != 1 || summary.Failed != 0 || summary.Skipped != 3 {
+		t.Errorf("Unexpected summary: %+v", summary)
+	}
+
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+	if !strings.Contains(string(content), "=== Request 1 ===") || !strings.Contains(string(content), "Body: id=1") {
+		t.Errorf("Expected the in-flight request to be written, got:\n%s", content)
+	}
+	if strings.Contains(string(content), "=== Request 2 ===") {
+		t.Errorf("Expected no requests after the interruption, got:\n%s", content)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// checkpointEntry is a single line of the checkpoint file.
+// Row is 1-based so it matches the "=== Request N ===" numbering.
+type checkpointEntry struct {
+	Row    int    `json:"row"`
+	Status string `json:"status"`
+	Code   int    `json:"code,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+const (
+	checkpointOK    = "ok"
+	checkpointError = "error"
+)
+
+// Checkpoint records which CSV rows have completed so that an interrupted
+// batch can be resumed. The file holds one JSON object per completed row and
+// is synced after every row.
+type Checkpoint struct {
+	file    *os.File
+	entries map[int]checkpointEntry
+}
+
+// OpenCheckpoint opens the checkpoint file at path. When resume is true the
+// rows already recorded in the file are loaded and new rows are appended;
+// otherwise the file is truncated.
+func OpenCheckpoint(path string, resume bool) (*Checkpoint, error) {
+	cp := &Checkpoint{entries: make(map[int]checkpointEntry)}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if resume {
+		if err := cp.load(path); err != nil {
+			return nil, err
+		}
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+	cp.file = file
+
+	return cp, nil
+}
+
+// load reads the entries of an existing checkpoint file.
+// A missing file is treated as an empty checkpoint.
+func (cp *Checkpoint) load(path string) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		var entry checkpointEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// The process may have died while writing the last line
+			continue
+		}
+		if entry.Row < 1 {
+			return fmt.Errorf("invalid row number %d on line %d", entry.Row, line)
+		}
+		cp.entries[entry.Row-1] = entry
+	}
+
+	return scanner.Err()
+}
+
+// Completed reports whether the row with the given 0-based index completed
+// successfully in a previous run. Rows that ended with an error or failed
+// assertions are run again.
+func (cp *Checkpoint) Completed(index int) bool {
+	entry, ok := cp.entries[index]
+	return ok && entry.Status == checkpointOK
+}
+
+// Record appends the outcome of a row to the checkpoint file
+func (cp *Checkpoint) Record(r *rowResult) error {
+	entry := checkpointEntry{Row: r.Index + 1, Status: checkpointOK}
+	if r.Response != nil {
+		entry.Code = r.Response.StatusCode
+	}
+	// Rows whose assertions failed are run again too, so a resumed run
+	// cannot turn a failing batch into a passing one
+	switch {
+	case r.Err != nil:
+		entry.Status = checkpointError
+		entry.Error = r.Err.Error()
+	case r.failed():
+		entry.Status = checkpointError
+		entry.Error = strings.Join(r.Failures, "; ")
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := cp.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	cp.entries[r.Index] = entry
+
+	return cp.file.Sync()
+}
+
+// Close closes the checkpoint file
+func (cp *Checkpoint) Close() error {
+	return cp.file.Close()
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckpointRecordAndResume(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "curl_batch_test_*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "checkpoint.jsonl")
+
+	cp, err := OpenCheckpoint(path, false)
+	if err != nil {
+		t.Fatalf("OpenCheckpoint failed: %v", err)
+	}
+	results := []*rowResult{
+		{Index: 0, Response: &httpResponse{StatusCode: 200}},
+		{Index: 1, Err: errors.New("request failed: connection refused")},
+		{Index: 2, Response: &httpResponse{StatusCode: 500}},
+	}
+	for _, r := range results {
+		if err := cp.Record(r); err != nil {
+			t.Fatalf("Record failed: %v", err)
+		}
+	}
+	cp.Close()
+
+	// Simulate a line cut short by a crash
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open checkpoint file: %v", err)
+	}
+	f.WriteString(`{"row":4,"sta`)
+	f.Close()
+
+	cp, err = OpenCheckpoint(path, true)
+	if err != nil {
+		t.Fatalf("OpenCheckpoint with resume failed: %v", err)
+	}
+	defer cp.Close()
+
+	expected := []bool{true, false, true, false}
+	for i, want := range expected {
+		if got := cp.Completed(i); got != want {
+			t.Errorf("Row %d: expected completed=%v, got %v", i, want, got)
+		}
+	}
+}
+
+func TestCheckpointWithoutResumeTruncates(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "curl_batch_test_*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "checkpoint.jsonl")
+	err = os.WriteFile(path, []byte(`{"row":1,"status":"ok","code":200}`+"\n"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint file: %v", err)
+	}
+
+	cp, err := OpenCheckpoint(path, false)
+	if err != nil {
+		t.Fatalf("OpenCheckpoint failed: %v", err)
+	}
+	defer cp.Close()
+
+	if cp.Completed(0) {
+		t.Error("Expected checkpoint to be empty without resume")
+	}
+}
+
+func TestRunResumeSkipsCompletedRows(t *testing.T) {
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Query().Get("id"))
+		fmt.Fprintf(w, "id=%s", r.URL.Query().Get("id"))
+	}))
+	defer server.Close()
+
+	tmpDir, err := os.MkdirTemp("", "curl_batch_test_*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	curlFile := filepath.Join(tmpDir, "curl.txt")
+	err = os.WriteFile(curlFile, []byte(`curl "`+server.URL+`/?id=${ID}"`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create curl file: %v", err)
+	}
+
+	csvFile := filepath.Join(tmpDir, "data.csv")
+	err = os.WriteFile(csvFile, []byte("ID\na\nb\nc\nd"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create CSV file: %v", err)
+	}
+
+	// Rows 1 and 3 completed in a previous run, row 2 failed
+	checkpointFile := filepath.Join(tmpDir, "checkpoint.jsonl")
+	checkpointContent := `{"row":1,"status":"ok","code":200}
+{"row":2,"status":"error","error":"request failed"}
+{"row":3,"status":"ok","code":200}
+`
+	err = os.WriteFile(checkpointFile, []byte(checkpointContent), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create checkpoint file: %v", err)
+	}
+
+	outputFile := filepath.Join(tmpDir, "output.txt")
+	batch, err := NewCurlBatch(curlFile, csvFile, outputFile, 0)
+	if err != nil {
+		t.Fatalf("NewCurlBatch failed: %v", err)
+	}
+	batch.Checkpoint, err = OpenCheckpoint(checkpointFile, true)
+	if err != nil {
+		t.Fatalf("OpenCheckpoint failed: %v", err)
+	}
+
+	if _, err := batch.Run(context.Background()); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if strings.Join(requested, ",") != "b,d" {
+		t.Errorf("Expected only rows b and d to be requested, got %v", requested)
+	}
+
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+	if !strings.Contains(string(content), "=== Request 2 ===") || !strings.Contains(string(content), "=== Request 4 ===") {
+		t.Errorf("Expected output to keep original row numbers, got:\n%s", content)
+	}
+	if strings.Contains(string(content), "=== Request 1 ===") || strings.Contains(string(content), "=== Request 3 ===") {
+		t.Errorf("Expected completed rows to be skipped, got:\n%s", content)
+	}
+
+	cp, err := OpenCheckpoint(checkpointFile, true)
+	if err != nil {
+		t.Fatalf("OpenCheckpoint failed: %v", err)
+	}
+	defer cp.Close()
+	for i := 0; i < 4; i++ {
+		if !cp.Completed(i) {
+			t.Errorf("Expected row %d to be completed after resume", i+1)
+		}
+	}
+}
+
+func TestRunResumeRerunsFailedAssertions(t *testing.T) {
+	var requested []string
+	broken := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Query().Get("id")
+		requested = append(requested, id)
+		if id == "b" && broken {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		fmt.Fprintf(w, "id=%s", id)
+	}))
+	defer server.Close()
+
+	tmpDir, err := os.MkdirTemp("", "curl_batch_test_*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	curlFile := filepath.Join(tmpDir, "curl.txt")
+	err = os.WriteFile(curlFile, []byte(`curl "`+server.URL+`/?id=${ID}"`), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create curl file: %v", err)
+	}
+
+	csvFile := filepath.Join(tmpDir, "data.csv")
+	err = os.WriteFile(csvFile, []byte("ID\na\nb\nc"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create CSV file: %v", err)
+	}
+
+	checkpointFile := filepath.Join(tmpDir, "checkpoint.jsonl")
+	outputFile := filepath.Join(tmpDir, "output.txt")
+
+	run := func(resume bool) *Summary {
+		batch, err := NewCurlBatch(curlFile, csvFile, outputFile, 0)
+		if err != nil {
+			t.Fatalf("NewCurlBatch failed: %v", err)
+		}
+		batch.Assertions, err = parseAssertions("2xx", nil, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("parseAssertions failed: %v", err)
+		}
+		batch.Checkpoint, err = OpenCheckpoint(checkpointFile, resume)
+		if err != nil {
+			t.Fatalf("OpenCheckpoint failed: %v", err)
+		}
+		summary, err := batch.Run(context.Background())
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		return summary
+	}
+
+	if summary := run(false); summary.Failed != 1 || summary.AssertionsFailed != 1 {
+		t.Fatalf("Expected row b to fail its assertion, got %+v", summary)
+	}
+
+	content, err := os.ReadFile(checkpointFile)
+	if err != nil {
+		t.Fatalf("Failed to read checkpoint file: %v", err)
+	}
+	if !strings.Contains(string(content), `{"row":2,"status":"error","code":500,"error":"`) {
+		t.Errorf("Expected row 2 to be recorded as an error, got:\n%s", content)
+	}
+
+	// The server is fixed, only row b is run again
+	broken = false
+	requested = nil
+	if summary := run(true); summary.Failed != 0 {
+		t.Errorf("Expected no failures after resume, got %+v", summary)
+	}
+	if strings.Join(requested, ",") != "b" {
+		t.Errorf("Expected only row b to be requested again, got %v", requested)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptrace"
+	"slices"
+	"strings"
+	"time"
+)
+
+// defaultIdleTimeout is how long an idle connection is kept in the pool
+const defaultIdleTimeout = 90 * time.Second
+
+// PoolOptions configures the connection pool of the HTTP clients a batch
+// shares between its rows
+type PoolOptions struct {
+	MaxIdleConnsPerHost int           // zero keeps one per worker, at least http.DefaultMaxIdleConnsPerHost
+	IdleTimeout         time.Duration // zero keeps idle connections until the batch ends
+	DisableHTTP2        bool
+	DisableKeepAlives   bool
+}
+
+// apply sets the pool options on a transport used by the given number of workers
+func (o PoolOptions) apply(transport *http.Transport, workers int) {
+	perHost := o.MaxIdleConnsPerHost
+	if perHost == 0 {
+		perHost = max(workers, http.DefaultMaxIdleConnsPerHost)
+	}
+	transport.MaxIdleConnsPerHost = perHost
+	transport.MaxIdleConns = max(transport.MaxIdleConns, perHost)
+	transport.IdleConnTimeout = o.IdleTimeout
+	transport.DisableKeepAlives = o.DisableKeepAlives
+
+	if o.DisableHTTP2 {
+		transport.ForceAttemptHTTP2 = false
+		transport.Protocols = new(http.Protocols)
+		transport.Protocols.SetHTTP1(true)
+	}
+}
+
+// client returns the HTTP client for a request. Clients are built once per
+// distinct transport configuration and kept for the whole batch, so rows
+// reuse each other's connections.
+func (cb *CurlBatch) client(creq *curlRequest) (*http.Client, error) {
+	key := cb.transportKey(creq)
+
+	cb.clientMu.Lock()
+	defer cb.clientMu.Unlock()
+
+	if client, ok := cb.clients[key]; ok {
+		return client, nil
+	}
+
+	transport, err := cb.newTransport(creq)
+	if err != nil {
+		return nil, err
+	}
+
+	// Like curl, redirects are not followed by the client itself
+	client := &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	if cb.clients == nil {
+		cb.clients = make(map[string]*http.Client)
+	}
+	cb.clients[key] = client
+	return client, nil
+}
+
+// transportKey identifies the options newTransport builds a transport from.
+// Templates usually have the same options on every row, so a batch tends to
+// need a single client.
+func (cb *CurlBatch) transportKey(creq *curlRequest) string {
+	var b strings.Builder
+
+	connectTimeout, _ := cb.timeouts(creq)
+	fmt.Fprintf(&b, "tls=%+v connect=%s http=%s", creq.TLS, connectTimeout, creq.HTTPVersion)
+
+	for _, hostPort := range slices.Sorted(maps.Keys(creq.Resolve)) {
+		fmt.Fprintf(&b, " resolve=%s:%s", hostPort, creq.Resolve[hostPort])
+	}
+
+	proxyURL, noProxy := cb.proxySettings(creq)
+	if proxyURL != nil {
+		fmt.Fprintf(&b, " proxy=%s", proxyURL)
+	}
+	if noProxy != "" {
+		fmt.Fprintf(&b, " noproxy=%s", noProxy)
+	}
+	if creq.ProxyUser != nil {
+		fmt.Fprintf(&b, " proxy-user=%s", creq.ProxyUser)
+	}
+
+	return b.String()
+}
+
+// closeIdleConnections closes the idle connections of every client of the batch
+func (cb *CurlBatch) closeIdleConnections() {
+	cb.clientMu.Lock()
+	defer cb.clientMu.Unlock()
+
+	for _, client := range cb.clients {
+		client.CloseIdleConnections()
+	}
+}
+
+// connectionTrace returns a trace that counts the connections requests
+// opened and the ones they reused from the pool
+func (cb *CurlBatch) connectionTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				cb.reusedConns.Add(1)
+			} else {
+				cb.newConns.Add(1)
+			}
+		},
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientCache(t *testing.T) {
+	cb := &CurlBatch{}
+
+	client := func(command string) *http.Client {
+		t.Helper()
+		c, err := cb.client(mustParseCurlCommand(t, command))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return c
+	}
+
+	first := client(`curl -H "X-Id: 1" https://api.example.com/users/1`)
+	if client(`curl -X POST -d a=1 https://other.example.com/users`) != first {
+		t.Error("Expected requests with the same transport options to share a client")
+	}
+	if client(`curl -k https://api.example.com`) == first {
+		t.Error("Expected -k to use a separate client")
+	}
+	if client(`curl --resolve api.example.com:443:127.0.0.1 https://api.example.com`) == first {
+		t.Error("Expected --resolve to use a separate client")
+	}
+	if client(`curl --connect-timeout 5 https://api.example.com`) == first {
+		t.Error("Expected --connect-timeout to use a separate client")
+	}
+	if len(cb.clients) != 4 {
+		t.Errorf("Expected 4 clients, got %d", len(cb.clients))
+	}
+}
+
+func TestPoolOptions(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	tests := []struct {
+		name   string
+		pool   PoolOptions
+		proto  string
+		new    int64
+		reused int64
+	}{
+		{
+			name:   "Keep-alive",
+			proto:  "HTTP/2.0",
+			new:    1,
+			reused: 2,
+		},
+		{
+			name:   "HTTP/2 disabled",
+			pool:   PoolOptions{DisableHTTP2: true},
+			proto:  "HTTP/1.1",
+			new:    1,
+			reused: 2,
+		},
+		{
+			name:  "Keep-alive disabled",
+			pool:  PoolOptions{DisableHTTP2: true, DisableKeepAlives: true},
+			proto: "HTTP/1.1",
+			new:   3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb := &CurlBatch{Pool: tt.pool}
+			defer cb.closeIdleConnections()
+
+			for range 3 {
+				resp, err := cb.executeRequest(mustParseCurlCommand(t, "curl -k "+server.URL))
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if string(resp.Body) != tt.proto {
+					t.Errorf("Expected protocol %s, got %s", tt.proto, resp.Body)
+				}
+			}
+
+			if cb.newConns.Load() != tt.new || cb.reusedConns.Load() != tt.reused {
+				t.Errorf("Expected %d new and %d reused connections, got %d and %d",
+					tt.new, tt.reused, cb.newConns.Load(), cb.reusedConns.Load())
+			}
+		})
+	}
+}
+
+func TestPoolOptionsApply(t *testing.T) {
+	transport := &http.Transport{MaxIdleConns: 100}
+	PoolOptions{}.apply(transport, 8)
+	if transport.MaxIdleConnsPerHost != 8 {
+		t.Errorf("Expected one idle connection per worker, got %d", transport.MaxIdleConnsPerHost)
+	}
+
+	transport = &http.Transport{MaxIdleConns: 100}
+	PoolOptions{MaxIdleConnsPerHost: 200}.apply(transport, 8)
+	if transport.MaxIdleConnsPerHost != 200 || transport.MaxIdleConns != 200 {
+		t.Errorf("Expected 200 idle connections, got %d per host and %d in total",
+			transport.MaxIdleConnsPerHost, transport.MaxIdleConns)
+	}
+}
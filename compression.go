@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// acceptEncoding is the Accept-Encoding header sent with --compressed
+const acceptEncoding = "gzip, deflate, br, zstd"
+
+// decodeBody decodes a response body according to its Content-Encoding.
+// Net/http only decodes gzip, and only when it added Accept-Encoding
+// itself, so a header copied into the template (e.g. from a browser)
+// would otherwise leave the body compressed. Like net/http, the
+// Content-Encoding and Content-Length headers are removed once the body
+// is decoded; unknown encodings are left as they are.
+func decodeBody(header http.Header, body []byte) ([]byte, error) {
+	contentEncoding := header.Get("Content-Encoding")
+	if contentEncoding == "" || len(body) == 0 {
+		return body, nil
+	}
+
+	// Encodings are listed in the order they were applied
+	encodings := strings.Split(contentEncoding, ",")
+	for i := len(encodings) - 1; i >= 0; i-- {
+		encoding := strings.ToLower(strings.TrimSpace(encodings[i]))
+		if encoding == "identity" || encoding == "" {
+			continue
+		}
+		if !knownEncoding(encoding) {
+			return body, nil
+		}
+
+		decoded, err := decode(encoding, body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s response body: %w", encoding, err)
+		}
+		body = decoded
+	}
+
+	header.Del("Content-Encoding")
+	header.Del("Content-Length")
+	return body, nil
+}
+
+// knownEncoding reports whether decode supports a content coding
+func knownEncoding(encoding string) bool {
+	switch encoding {
+	case "gzip", "x-gzip", "deflate", "br", "zstd":
+		return true
+	}
+	return false
+}
+
+// decode removes one content coding
+func decode(encoding string, body []byte) ([]byte, error) {
+	var r io.Reader
+	switch encoding {
+	case "gzip", "x-gzip":
+		gr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		r = gr
+	case "deflate":
+		// "deflate" should be zlib wrapped, but some servers send raw
+		// deflate data; curl accepts both
+		zr, err := zlib.NewReader(bytes.NewReader(body))
+		if err != nil {
+			r = flate.NewReader(bytes.NewReader(body))
+		} else {
+			defer zr.Close()
+			r = zr
+		}
+	case "br":
+		r = brotli.NewReader(bytes.NewReader(body))
+	case "zstd":
+		zr, err := zstd.NewReader(bytes.NewReader(body), zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	}
+	return io.ReadAll(r)
+}
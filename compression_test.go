@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// compress encodes data with a content coding
+func compress(t *testing.T, encoding string, data []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	var err error
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, err = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "zstd":
+		w, err = zstd.NewWriter(&buf)
+	default:
+		t.Fatalf("Unknown encoding %q", encoding)
+	}
+	if err != nil {
+		t.Fatalf("Failed to create %s writer: %v", encoding, err)
+	}
+
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to compress: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestCompressedOption(t *testing.T) {
+	tests := []struct {
+		command  string
+		expected string
+	}{
+		{`curl --compressed https://api.example.com`, acceptEncoding},
+		{`curl --compressed -H "Accept-Encoding: gzip" https://api.example.com`, "gzip"},
+		{`curl https://api.example.com`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			req := mustParseCurlCommand(t, tt.command)
+			if got := req.Header.Get("Accept-Encoding"); got != tt.expected {
+				t.Errorf("Expected Accept-Encoding %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestResponseDecompression(t *testing.T) {
+	const body = `{"message":"hello, compressed world"}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := r.URL.Query().Get("encoding")
+		data := []byte(body)
+		switch encoding {
+		case "":
+		case "gzip,br":
+			data = compress(t, "br", compress(t, "gzip", data))
+		case "unknown":
+			data = []byte("opaque")
+		case "broken":
+			encoding = "gzip"
+			data = []byte("not gzip")
+		default:
+			data = compress(t, encoding, data)
+		}
+		if encoding == "raw-deflate" {
+			encoding = "deflate"
+		}
+		if encoding != "" {
+			w.Header().Set("Content-Encoding", encoding)
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		encoding string
+		options  string
+		expected string
+		errText  string
+	}{
+		{name: "Gzip with a browser header", encoding: "gzip", options: `-H "Accept-Encoding: gzip, deflate, br"`, expected: body},
+		{name: "Gzip with --compressed", encoding: "gzip", options: `--compressed`, expected: body},
+		{name: "Gzip without a header", encoding: "gzip", expected: body},
+		{name: "Deflate", encoding: "deflate", options: `--compressed`, expected: body},
+		{name: "Raw deflate", encoding: "raw-deflate", options: `--compressed`, expected: body},
+		{name: "Brotli", encoding: "br", options: `--compressed`, expected: body},
+		{name: "Zstandard", encoding: "zstd", options: `--compressed`, expected: body},
+		{name: "Several encodings", encoding: "gzip,br", options: `--compressed`, expected: body},
+		{name: "Not encoded", options: `--compressed`, expected: body},
+		{name: "Unknown encoding", encoding: "unknown", options: `--compressed`, expected: "opaque"},
+		{name: "Invalid data", encoding: "broken", options: `--compressed`, errText: "failed to decode gzip response body"},
+	}
+
+	cb := &CurlBatch{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			command := `curl ` + tt.options + ` "` + server.URL + `/?encoding=` + tt.encoding + `"`
+			resp, err := cb.executeRequest(mustParseCurlCommand(t, command))
+
+			if tt.errText != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errText) {
+					t.Errorf("Expected error containing %q, got %v", tt.errText, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if string(resp.Body) != tt.expected {
+				t.Errorf("Expected body %q, got %q", tt.expected, resp.Body)
+			}
+			if tt.expected == body && resp.Header.Get("Content-Encoding") != "" {
+				t.Errorf("Expected Content-Encoding to be removed, got %q", resp.Header.Get("Content-Encoding"))
+			}
+		})
+	}
+}
@@ -0,0 +1,460 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cookie sessions selected with -cookie-session
+const (
+	cookieSessionRow    = "row"    // every CSV row starts with its own jar
+	cookieSessionShared = "shared" // a single jar is shared by the whole batch
+)
+
+// checkCookieSession returns an error if session is not a known cookie session
+func checkCookieSession(session string) error {
+	switch session {
+	case cookieSessionRow, cookieSessionShared:
+		return nil
+	default:
+		return fmt.Errorf("unknown cookie session %q: use %s or %s", session, cookieSessionRow, cookieSessionShared)
+	}
+}
+
+// jarCookie is a cookie stored in a CookieJar
+type jarCookie struct {
+	Domain   string // without a leading dot
+	HostOnly bool   // false if the cookie is also sent to subdomains
+	Path     string
+	Secure   bool
+	HTTPOnly bool
+	Expires  time.Time // zero for session cookies
+	Name     string
+	Value    string
+}
+
+// CookieJar is an http.CookieJar that can be read from and written to
+// cookie files in the Netscape format used by curl's -b and -c options
+type CookieJar struct {
+	mu      sync.Mutex
+	cookies []*jarCookie
+}
+
+// NewCookieJar returns an empty cookie jar
+func NewCookieJar() *CookieJar {
+	return &CookieJar{}
+}
+
+// SetCookies stores the cookies received in a response from u
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	host := strings.ToLower(u.Hostname())
+	now := time.Now()
+
+	for _, c := range cookies {
+		stored := &jarCookie{
+			Domain:   host,
+			HostOnly: true,
+			Path:     c.Path,
+			Secure:   c.Secure,
+			HTTPOnly: c.HttpOnly,
+			Name:     c.Name,
+			Value:    c.Value,
+		}
+
+		if c.Domain != "" {
+			domain := strings.ToLower(strings.TrimPrefix(c.Domain, "."))
+			if !domainMatch(host, domain) {
+				continue
+			}
+			stored.Domain = domain
+			stored.HostOnly = false
+		}
+
+		if !strings.HasPrefix(stored.Path, "/") {
+			stored.Path = defaultCookiePath(u.Path)
+		}
+
+		switch {
+		case c.MaxAge < 0:
+			stored.Expires = time.Unix(1, 0)
+		case c.MaxAge > 0:
+			stored.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		case !c.Expires.IsZero():
+			stored.Expires = c.Expires
+		}
+
+		j.store(stored, now)
+	}
+}
+
+// store adds a cookie, replacing a cookie with the same domain, path and
+// name. Expired cookies delete the cookie they replace.
+func (j *CookieJar) store(c *jarCookie, now time.Time) {
+	j.cookies = slices.DeleteFunc(j.cookies, func(existing *jarCookie) bool {
+		return existing.Domain == c.Domain && existing.Path == c.Path && existing.Name == c.Name
+	})
+	if c.Expires.IsZero() || c.Expires.After(now) {
+		j.cookies = append(j.cookies, c)
+	}
+}
+
+// Cookies returns the cookies to send in a request to u, longest path first
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	host := strings.ToLower(u.Hostname())
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	now := time.Now()
+
+	var matched []*jarCookie
+	for _, c := range j.cookies {
+		switch {
+		case !c.Expires.IsZero() && !c.Expires.After(now):
+		case c.Secure && u.Scheme != "https":
+		case c.HostOnly && host != c.Domain:
+		case !c.HostOnly && !domainMatch(host, c.Domain):
+		case !pathMatch(path, c.Path):
+		default:
+			matched = append(matched, c)
+		}
+	}
+
+	slices.SortStableFunc(matched, func(a, b *jarCookie) int {
+		return len(b.Path) - len(a.Path)
+	})
+
+	cookies := make([]*http.Cookie, 0, len(matched))
+	for _, c := range matched {
+		cookies = append(cookies, &http.Cookie{Name: c.Name, Value: c.Value})
+	}
+	return cookies
+}
+
+// domainMatch reports whether host is domain or one of its subdomains
+func domainMatch(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// pathMatch reports whether a request path is within a cookie path
+func pathMatch(path, cookiePath string) bool {
+	if !strings.HasPrefix(path, cookiePath) {
+		return false
+	}
+	return len(path) == len(cookiePath) || strings.HasSuffix(cookiePath, "/") || path[len(cookiePath)] == '/'
+}
+
+// defaultCookiePath returns the path of a cookie set without a Path
+// attribute: the directory of the request path
+func defaultCookiePath(path string) string {
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		return "/"
+	}
+	return path[:i]
+}
+
+// Merge adds the cookies of another jar, replacing cookies with the same
+// domain, path and name
+func (j *CookieJar) Merge(other *CookieJar) {
+	other.mu.Lock()
+	cookies := make([]jarCookie, 0, len(other.cookies))
+	for _, c := range other.cookies {
+		cookies = append(cookies, *c)
+	}
+	other.mu.Unlock()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	for _, c := range cookies {
+		j.store(&c, now)
+	}
+}
+
+// Load adds the cookies of a Netscape format cookie file
+func (j *CookieJar) Load(r io.Reader) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		httpOnly := false
+		if rest, ok := strings.CutPrefix(line, "#HttpOnly_"); ok {
+			line = rest
+			httpOnly = true
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) == 6 {
+			// A cookie with an empty value may lose its last tab
+			fields = append(fields, "")
+		}
+		if len(fields) != 7 {
+			return fmt.Errorf("invalid cookie file line %d: expected 7 tab separated fields", lineNumber)
+		}
+
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid cookie file line %d: invalid expiry %q", lineNumber, fields[4])
+		}
+
+		c := &jarCookie{
+			Domain:   strings.ToLower(strings.TrimPrefix(fields[0], ".")),
+			HostOnly: !strings.EqualFold(fields[1], "TRUE"),
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			HTTPOnly: httpOnly,
+			Name:     fields[5],
+			Value:    fields[6],
+		}
+		if expires > 0 {
+			c.Expires = time.Unix(expires, 0)
+		}
+		j.store(c, now)
+	}
+
+	return scanner.Err()
+}
+
+// Save writes every cookie in the Netscape format, session cookies included
+func (j *CookieJar) Save(w io.Writer) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.save(w)
+}
+
+func (j *CookieJar) save(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("# Netscape HTTP Cookie File\n")
+	b.WriteString("# This file was generated by curl-batch.\n\n")
+
+	for _, c := range j.cookies {
+		domain := c.Domain
+		if !c.HostOnly {
+			domain = "." + domain
+		}
+		if c.HTTPOnly {
+			domain = "#HttpOnly_" + domain
+		}
+		var expires int64
+		if !c.Expires.IsZero() {
+			expires = c.Expires.Unix()
+		}
+		fmt.Fprintf(&b, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			domain, netscapeBool(!c.HostOnly), c.Path, netscapeBool(c.Secure), expires, c.Name, c.Value)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// netscapeBool formats a boolean field of a Netscape cookie file
+func netscapeBool(v bool) string {
+	if v {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+// LoadFile adds the cookies of a cookie file. Like curl, a file that does
+// not exist yet is not an error, so -b and -c can name the same file.
+func (j *CookieJar) LoadFile(filename string) error {
+	f, err := os.Open(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read cookie file: %w", err)
+	}
+	defer f.Close()
+
+	if err := j.Load(f); err != nil {
+		return fmt.Errorf("failed to read cookie file %s: %w", filename, err)
+	}
+	return nil
+}
+
+// SaveFile writes the jar to a cookie file. The file is replaced atomically
+// so that concurrent rows sharing the jar never leave a partial file.
+func (j *CookieJar) SaveFile(filename string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to write cookie jar: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := j.save(tmp); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cookie jar: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cookie jar: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return fmt.Errorf("failed to write cookie jar: %w", err)
+	}
+	return nil
+}
+
+// cookieJar returns the jar for the request of a row, or nil if the cookie
+// engine is off. As in curl, -b and -c turn it on; with -cookie-session
+// shared it is always on so that cookies flow from one row to the next.
+func (cb *CurlBatch) cookieJar(creq *curlRequest) (*CookieJar, error) {
+	cb.cookieMu.Lock()
+	defer cb.cookieMu.Unlock()
+
+	if cb.CookieSession == cookieSessionShared {
+		if cb.sharedJar == nil {
+			jar, err := cb.startingJar(creq)
+			if err != nil {
+				return nil, err
+			}
+			cb.sharedJar = jar
+		}
+		return cb.sharedJar, nil
+	}
+
+	if len(creq.CookieFiles) == 0 && creq.CookieJarFile == "" {
+		return nil, nil
+	}
+	return cb.startingJar(creq)
+}
+
+// startingJar returns a new jar with the cookies of the request's -b files.
+// cb.cookieMu must be held.
+func (cb *CurlBatch) startingJar(creq *curlRequest) (*CookieJar, error) {
+	jar := NewCookieJar()
+	for _, filename := range creq.CookieFiles {
+		file, err := cb.cookieFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		jar.Merge(file)
+	}
+	return jar, nil
+}
+
+// cookieFile returns the cookies of a -b file, read the first time it is
+// needed and kept for the rest of the batch. Run reads the files of every
+// row before the first row starts, so a file that rows also write with -c
+// gives each row the same starting cookies. cb.cookieMu must be held.
+func (cb *CurlBatch) cookieFile(filename string) (*CookieJar, error) {
+	if file, ok := cb.cookieFiles[filename]; ok {
+		return file.jar, file.err
+	}
+
+	jar := NewCookieJar()
+	err := jar.LoadFile(filename)
+	if cb.cookieFiles == nil {
+		cb.cookieFiles = make(map[string]cookieFile)
+	}
+	cb.cookieFiles[filename] = cookieFile{jar: jar, err: err}
+	return jar, err
+}
+
+// cookieFile is a -b cookie file as it was read, or the error reading it
+type cookieFile struct {
+	jar *CookieJar
+	err error
+}
+
+// loadCookieFiles reads the -b files of the given rows. Errors are kept
+// and reported by the rows that use the file.
+func (cb *CurlBatch) loadCookieFiles(rows []int) {
+	cb.cookieMu.Lock()
+	defer cb.cookieMu.Unlock()
+
+	for _, i := range rows {
+		creq, err := parseCurlCommand(cb.replaceTemplate(cb.CurlTemplate, cb.CSVData[i]))
+		if err != nil {
+			// Reported when the row runs
+			continue
+		}
+		for _, filename := range creq.CookieFiles {
+			cb.cookieFile(filename)
+		}
+	}
+}
+
+// saveCookieJar writes the jar of a finished row to its -c file. With
+// -cookie-session row the jars of all rows writing the same file are merged,
+// a cookie with the same domain, path and name replacing the one of a row
+// that finished earlier, so the file holds the cookies of the whole batch.
+func (cb *CurlBatch) saveCookieJar(creq *curlRequest) error {
+	if creq.Jar == nil || creq.CookieJarFile == "" {
+		return nil
+	}
+
+	cb.cookieMu.Lock()
+	defer cb.cookieMu.Unlock()
+
+	jar := creq.Jar
+	if cb.CookieSession != cookieSessionShared {
+		jar = cb.savedJars[creq.CookieJarFile]
+		if jar == nil {
+			jar = NewCookieJar()
+			if cb.savedJars == nil {
+				cb.savedJars = make(map[string]*CookieJar)
+			}
+			cb.savedJars[creq.CookieJarFile] = jar
+		}
+		jar.Merge(creq.Jar)
+	}
+	return jar.SaveFile(creq.CookieJarFile)
+}
+
+// addCookies adds the cookies given with -b and those in the jar to the
+// Cookie header, after any Cookie header given with -H
+func addCookies(req *http.Request, creq *curlRequest) {
+	var cookies []string
+	if header := req.Header.Get("Cookie"); header != "" {
+		cookies = append(cookies, header)
+	}
+	if creq.Cookie != "" {
+		cookies = append(cookies, creq.Cookie)
+	}
+	if creq.Jar != nil {
+		for _, c := range creq.Jar.Cookies(req.URL) {
+			cookies = append(cookies, c.String())
+		}
+	}
+	if len(cookies) > 0 {
+		req.Header.Set("Cookie", strings.Join(cookies, "; "))
+	}
+}
+
+// storeCookies stores the cookies set by a response in the request's jar
+func storeCookies(creq *curlRequest, u *url.URL, resp *http.Response) {
+	if creq.Jar != nil {
+		creq.Jar.SetCookies(u, resp.Cookies())
+	}
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustParseURL(t *testing.T, rawURL string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("Failed to parse URL %q: %v", rawURL, err)
+	}
+	return u
+}
+
+// cookieHeader formats the cookies the jar sends to rawURL like a Cookie header
+func cookieHeader(t *testing.T, jar *CookieJar, rawURL string) string {
+	t.Helper()
+	var pairs []string
+	for _, c := range jar.Cookies(mustParseURL(t, rawURL)) {
+		pairs = append(pairs, c.String())
+	}
+	return strings.Join(pairs, "; ")
+}
+
+func TestCookieJarMatching(t *testing.T) {
+	jar := NewCookieJar()
+	jar.SetCookies(mustParseURL(t, "http://www.example.com/app/login"), []*http.Cookie{
+		{Name: "host", Value: "1"},
+		{Name: "domain", Value: "2", Domain: ".example.com", Path: "/"},
+		{Name: "secure", Value: "3", Path: "/", Secure: true},
+		{Name: "admin", Value: "4", Path: "/app/admin"},
+		{Name: "other", Value: "5", Domain: "other.com"},
+		{Name: "expired", Value: "6", MaxAge: -1},
+		{Name: "old", Value: "7", Expires: time.Now().Add(-time.Hour)},
+	})
+
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"http://www.example.com/app/login", "host=1; domain=2"},
+		{"https://www.example.com/app/admin/users", "admin=4; host=1; domain=2; secure=3"},
+		{"http://www.example.com/application", "domain=2"},
+		{"http://api.example.com/app", "domain=2"},
+		{"http://example.com/", "domain=2"},
+		{"http://other.com/", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			if got := cookieHeader(t, jar, tt.url); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+
+	// A cookie is replaced by name, domain and path, and deleted when expired
+	u := mustParseURL(t, "http://www.example.com/app/login")
+	jar.SetCookies(u, []*http.Cookie{{Name: "host", Value: "new"}})
+	jar.SetCookies(u, []*http.Cookie{{Name: "domain", Domain: "example.com", Path: "/", MaxAge: -1}})
+	if got := cookieHeader(t, jar, u.String()); got != "host=new" {
+		t.Errorf("Expected %q, got %q", "host=new", got)
+	}
+}
+
+func TestCookieJarNetscapeFormat(t *testing.T) {
+	file := "# Netscape HTTP Cookie File\n" +
+		"# https://curl.se/docs/http-cookies.html\n" +
+		"\n" +
+		"www.example.com\tFALSE\t/\tFALSE\t0\tsession\tabc\n" +
+		".example.com\tTRUE\t/api\tTRUE\t4102444800\ttoken\txyz\n" +
+		"#HttpOnly_www.example.com\tFALSE\t/\tFALSE\t0\tsid\t42\n" +
+		"www.example.com\tFALSE\t/\tFALSE\t0\tempty\n" +
+		"www.example.com\tFALSE\t/\tFALSE\t1\tgone\tvalue\n"
+
+	jar := NewCookieJar()
+	if err := jar.Load(strings.NewReader(file)); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if got := cookieHeader(t, jar, "https://api.example.com/api/v1"); got != "token=xyz" {
+		t.Errorf("Expected %q, got %q", "token=xyz", got)
+	}
+	if got := cookieHeader(t, jar, "http://www.example.com/"); got != "session=abc; sid=42; empty=" {
+		t.Errorf("Expected %q, got %q", "session=abc; sid=42; empty=", got)
+	}
+
+	var saved strings.Builder
+	if err := jar.Save(&saved); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	expected := "# Netscape HTTP Cookie File\n" +
+		"# This file was generated by curl-batch.\n" +
+		"\n" +
+		"www.example.com\tFALSE\t/\tFALSE\t0\tsession\tabc\n" +
+		".example.com\tTRUE\t/api\tTRUE\t4102444800\ttoken\txyz\n" +
+		"#HttpOnly_www.example.com\tFALSE\t/\tFALSE\t0\tsid\t42\n" +
+		"www.example.com\tFALSE\t/\tFALSE\t0\tempty\t\n"
+	if saved.String() != expected {
+		t.Errorf("Expected saved file:\n%s\ngot:\n%s", expected, saved.String())
+	}
+
+	for _, invalid := range []string{
+		"example.com\tFALSE\t/\tFALSE\t0\n",
+		"example.com\tFALSE\t/\tFALSE\tnever\tname\tvalue\n",
+	} {
+		if err := NewCookieJar().Load(strings.NewReader(invalid)); err == nil {
+			t.Errorf("Expected error for %q", invalid)
+		}
+	}
+}
+
+func TestParseCookieOptions(t *testing.T) {
+	req := mustParseCurlCommand(t, `curl -b "a=1" --cookie "b=2; c=3" -b cookies.txt -c jar.txt https://api.example.com`)
+	if req.Cookie != "a=1; b=2; c=3" {
+		t.Errorf("Expected inline cookies %q, got %q", "a=1; b=2; c=3", req.Cookie)
+	}
+	if len(req.CookieFiles) != 1 || req.CookieFiles[0] != "cookies.txt" {
+		t.Errorf("Expected cookie files [cookies.txt], got %v", req.CookieFiles)
+	}
+	if req.CookieJarFile != "jar.txt" {
+		t.Errorf("Expected cookie jar jar.txt, got %q", req.CookieJarFile)
+	}
+}
+
+func TestCookiesSentAndStored(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/"})
+		http.Redirect(w, r, "/echo", http.StatusFound)
+	})
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Cookie")))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		command  string
+		jar      bool
+		expected string
+	}{
+		{
+			name:     "Inline cookies",
+			command:  `curl -H "Cookie: a=1" -b "b=2" ` + server.URL + `/echo`,
+			expected: "a=1; b=2",
+		},
+		{
+			name:     "Cookie set by a redirect without a jar",
+			command:  `curl -L ` + server.URL + `/login`,
+			expected: "",
+		},
+		{
+			name:     "Cookie set by a redirect is sent to its target",
+			command:  `curl -L -b "b=2" ` + server.URL + `/login`,
+			jar:      true,
+			expected: "b=2; session=abc",
+		},
+	}
+
+	cb := &CurlBatch{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := mustParseCurlCommand(t, tt.command)
+			if tt.jar {
+				req.Jar = NewCookieJar()
+			}
+			resp, err := cb.executeRequest(req)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if string(resp.Body) != tt.expected {
+				t.Errorf("Expected cookies %q, got %q", tt.expected, resp.Body)
+			}
+		})
+	}
+}
+
+func TestCookieSession(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user := r.URL.Query().Get("login"); user != "" {
+			http.SetCookie(w, &http.Cookie{Name: "user", Value: user})
+		}
+		w.Write([]byte("cookie=" + r.Header.Get("Cookie")))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		session  string
+		expected []string
+	}{
+		{
+			name:     "Jar per row",
+			session:  cookieSessionRow,
+			expected: []string{"Body: cookie=seed=1\n", "Body: cookie=seed=1\n"},
+		},
+		{
+			name:     "Shared jar",
+			session:  cookieSessionShared,
+			expected: []string{"Body: cookie=seed=1\n", "Body: cookie=seed=1; user=alice\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir, err := os.MkdirTemp("", "curl_batch_test_*")
+			if err != nil {
+				t.Fatalf("Failed to create temp dir: %v", err)
+			}
+			defer os.RemoveAll(tmpDir)
+
+			host := mustParseURL(t, server.URL).Hostname()
+			cookieFile := filepath.Join(tmpDir, "cookies.txt")
+			err = os.WriteFile(cookieFile, []byte(host+"\tFALSE\t/\tFALSE\t0\tseed\t1\n"), 0644)
+			if err != nil {
+				t.Fatalf("Failed to create cookie file: %v", err)
+			}
+
+			jarFile := filepath.Join(tmpDir, "jar.txt")
+			curlFile := filepath.Join(tmpDir, "curl.txt")
+			command := `curl -b ` + cookieFile + ` -c ` + jarFile + ` "` + server.URL + `/?login=${LOGIN}"`
+			if err := os.WriteFile(curlFile, []byte(command), 0644); err != nil {
+				t.Fatalf("Failed to create curl file: %v", err)
+			}
+
+			csvFile := filepath.Join(tmpDir, "data.csv")
+			if err := os.WriteFile(csvFile, []byte("LOGIN\nalice\nbob\n"), 0644); err != nil {
+				t.Fatalf("Failed to create CSV file: %v", err)
+			}
+
+			outputFile := filepath.Join(tmpDir, "output.txt")
+			batch, err := NewCurlBatch(curlFile, csvFile, outputFile, 0)
+			if err != nil {
+				t.Fatalf("NewCurlBatch failed: %v", err)
+			}
+			batch.CookieSession = tt.session
+
+			if _, err := batch.Run(context.Background()); err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+
+			content, err := os.ReadFile(outputFile)
+			if err != nil {
+				t.Fatalf("Failed to read output file: %v", err)
+			}
+			blocks := strings.Split(strings.TrimSpace(string(content)), "\n\n")
+			if len(blocks) != len(tt.expected) {
+				t.Fatalf("Expected %d request blocks, got %d:\n%s", len(tt.expected), len(blocks), content)
+			}
+			for i, block := range blocks {
+				if !strings.Contains(block+"\n", tt.expected[i]) {
+					t.Errorf("Block %d: expected %q in:\n%s", i, tt.expected[i], block)
+				}
+			}
+
+			jar, err := os.ReadFile(jarFile)
+			if err != nil {
+				t.Fatalf("Failed to read cookie jar: %v", err)
+			}
+			if !strings.Contains(string(jar), "\tuser\tbob\n") {
+				t.Errorf("Expected the cookie jar to contain the login cookie, got:\n%s", jar)
+			}
+		})
+	}
+}
+
+func TestCookieSessionRowWithSameCookieFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := r.URL.Query().Get("login")
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: user})
+		http.SetCookie(w, &http.Cookie{Name: "user_" + user, Value: "1"})
+		w.Write([]byte("cookie=" + r.Header.Get("Cookie")))
+	}))
+	defer server.Close()
+
+	for _, concurrency := range []int{1, 3} {
+		t.Run(fmt.Sprintf("Concurrency %d", concurrency), func(t *testing.T) {
+			tmpDir, err := os.MkdirTemp("", "curl_batch_test_*")
+			if err != nil {
+				t.Fatalf("Failed to create temp dir: %v", err)
+			}
+			defer os.RemoveAll(tmpDir)
+
+			host := mustParseURL(t, server.URL).Hostname()
+			cookieFile := filepath.Join(tmpDir, "cookies.txt")
+			err = os.WriteFile(cookieFile, []byte(host+"\tFALSE\t/\tFALSE\t0\tseed\t1\n"), 0644)
+			if err != nil {
+				t.Fatalf("Failed to create cookie file: %v", err)
+			}
+
+			curlFile := filepath.Join(tmpDir, "curl.txt")
+			command := `curl -b ` + cookieFile + ` -c ` + cookieFile + ` "` + server.URL + `/?login=${LOGIN}"`
+			if err := os.WriteFile(curlFile, []byte(command), 0644); err != nil {
+				t.Fatalf("Failed to create curl file: %v", err)
+			}
+
+			csvFile := filepath.Join(tmpDir, "data.csv")
+			if err := os.WriteFile(csvFile, []byte("LOGIN\nalice\nbob\ncarol\n"), 0644); err != nil {
+				t.Fatalf("Failed to create CSV file: %v", err)
+			}
+
+			outputFile := filepath.Join(tmpDir, "output.txt")
+			batch, err := NewCurlBatch(curlFile, csvFile, outputFile, 0)
+			if err != nil {
+				t.Fatalf("NewCurlBatch failed: %v", err)
+			}
+			batch.Concurrency = concurrency
+
+			if _, err := batch.Run(context.Background()); err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+
+			content, err := os.ReadFile(outputFile)
+			if err != nil {
+				t.Fatalf("Failed to read output file: %v", err)
+			}
+			if n := strings.Count(string(content), "Body: cookie=seed=1\n"); n != 3 {
+				t.Errorf("Expected every row to send only the cookies of the file, got:\n%s", content)
+			}
+
+			jar, err := os.ReadFile(cookieFile)
+			if err != nil {
+				t.Fatalf("Failed to read cookie jar: %v", err)
+			}
+			for _, cookie := range []string{"\tseed\t1\n", "\tuser_alice\t1\n", "\tuser_bob\t1\n", "\tuser_carol\t1\n", "\tsession\t"} {
+				if !strings.Contains(string(jar), cookie) {
+					t.Errorf("Expected the cookie jar to contain %q, got:\n%s", cookie, jar)
+				}
+			}
+			if n := strings.Count(string(jar), "\tsession\t"); n != 1 {
+				t.Errorf("Expected a single session cookie in the jar, got:\n%s", jar)
+			}
+		})
+	}
+}
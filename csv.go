@@ -9,20 +9,27 @@ import (
 // readCSVData reads a CSV file and returns data as a slice of maps
 // where each map represents a row with column headers as keys
 func readCSVData(filename string) ([]map[string]string, error) {
+	_, data, err := readCSVFile(filename)
+	return data, err
+}
+
+// readCSVFile reads a CSV file like readCSVData and additionally returns
+// the column headers in file order
+func readCSVFile(filename string) ([]string, []map[string]string, error) {
 	file, err := os.Open(filename)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer file.Close()
 
 	reader := csv.NewReader(file)
 	records, err := reader.ReadAll()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	if len(records) == 0 {
-		return nil, fmt.Errorf("CSV file is empty")
+		return nil, nil, fmt.Errorf("CSV file is empty")
 	}
 
 	headers := records[0]
@@ -30,7 +37,7 @@ func readCSVData(filename string) ([]map[string]string, error) {
 
 	for i, record := range records[1:] {
 		if len(record) != len(headers) {
-			return nil, fmt.Errorf("record %d has %d fields, expected %d", i+2, len(record), len(headers))
+			return nil, nil, fmt.Errorf("record %d has %d fields, expected %d", i+2, len(record), len(headers))
 		}
 
 		row := make(map[string]string)
@@ -40,5 +47,5 @@ func readCSVData(filename string) ([]map[string]string, error) {
 		data = append(data, row)
 	}
 
-	return data, nil
+	return headers, data, nil
 }
@@ -444,3 +444,30 @@ func TestReadCSVDataSpecialJSONContent(t *testing.T) {
 		t.Errorf("Expected %v, got %v", expected, result)
 	}
 }
+
+func TestReadCSVFileHeaders(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "test_*.csv")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	_, err = tmpFile.WriteString("NAME,EMAIL,AGE\n田中太郎,tanaka@example.com,30")
+	if err != nil {
+		t.Fatalf("Failed to write to temp file: %v", err)
+	}
+	tmpFile.Close()
+
+	headers, data, err := readCSVFile(tmpFile.Name())
+	if err != nil {
+		t.Fatalf("readCSVFile failed: %v", err)
+	}
+
+	expectedHeaders := []string{"NAME", "EMAIL", "AGE"}
+	if !reflect.DeepEqual(headers, expectedHeaders) {
+		t.Errorf("Expected headers %v, got %v", expectedHeaders, headers)
+	}
+	if len(data) != 1 || data[0]["AGE"] != "30" {
+		t.Errorf("Unexpected data %v", data)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// curl data flags, which all add to the request body
+const (
+	dataASCII     = "ascii"     // -d, --data, --data-ascii
+	dataRaw       = "raw"       // --data-raw
+	dataBinary    = "binary"    // --data-binary
+	dataURLEncode = "urlencode" // --data-urlencode
+)
+
+// dataValue returns the body part contributed by a single data flag,
+// following curl's semantics:
+//
+//   - -d/--data/--data-ascii: "@file" reads the file and strips CR and LF
+//   - --data-raw: the value is used literally, "@" has no special meaning
+//   - --data-binary: "@file" reads the file exactly as is
+//   - --data-urlencode: "content", "=content", "name=content", "@file" or
+//     "name@file", with the content URL-encoded
+func dataValue(kind, value string) (string, error) {
+	switch kind {
+	case dataRaw:
+		return value, nil
+	case dataASCII:
+		if file, ok := strings.CutPrefix(value, "@"); ok {
+			content, err := readDataFile(file)
+			if err != nil {
+				return "", err
+			}
+			return strings.NewReplacer("\r", "", "\n", "").Replace(content), nil
+		}
+		return value, nil
+	case dataBinary:
+		if file, ok := strings.CutPrefix(value, "@"); ok {
+			return readDataFile(file)
+		}
+		return value, nil
+	case dataURLEncode:
+		return urlEncodeData(value)
+	default:
+		return "", fmt.Errorf("unknown data kind %q", kind)
+	}
+}
+
+// urlEncodeData implements the --data-urlencode forms
+func urlEncodeData(value string) (string, error) {
+	i := strings.IndexAny(value, "=@")
+	if i < 0 {
+		return curlEscape(value), nil
+	}
+
+	name := value[:i]
+	content := value[i+1:]
+	if value[i] == '@' {
+		fileContent, err := readDataFile(content)
+		if err != nil {
+			return "", err
+		}
+		content = fileContent
+	}
+
+	if name == "" {
+		return curlEscape(content), nil
+	}
+	return name + "=" + curlEscape(content), nil
+}
+
+// curlEscape percent-encodes everything except unreserved characters, the
+// way curl does (spaces become %20, not +)
+func curlEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// readDataFile reads a file referenced by a data flag
+func readDataFile(filename string) (string, error) {
+	if filename == "-" {
+		return "", fmt.Errorf("reading data from stdin is not supported")
+	}
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return "", fmt.Errorf("failed to read data file: %w", err)
+	}
+	return string(content), nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDataValue(t *testing.T) {
+	tmpDir, err := os.MkdirTemp("", "curl_data_test_*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	dataFile := filepath.Join(tmpDir, "data.txt")
+	err = os.WriteFile(dataFile, []byte("line1\r\nline2\n"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create data file: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		kind     string
+		value    string
+		expected string
+		hasError bool
+	}{
+		{name: "Literal data", kind: dataASCII, value: "a=1", expected: "a=1"},
+		{name: "Data from file strips newlines", kind: dataASCII, value: "@" + dataFile, expected: "line1line2"},
+		{name: "Raw data keeps @", kind: dataRaw, value: "@" + dataFile, expected: "@" + dataFile},
+		{name: "Binary data from file keeps newlines", kind: dataBinary, value: "@" + dataFile, expected: "line1\r\nline2\n"},
+		{name: "Binary literal", kind: dataBinary, value: "a\nb", expected: "a\nb"},
+		{name: "Urlencode content", kind: dataURLEncode, value: "hello world&more", expected: "hello%20world%26more"},
+		{name: "Urlencode =content", kind: dataURLEncode, value: "=a=b", expected: "a%3Db"},
+		{name: "Urlencode name=content", kind: dataURLEncode, value: "name=田中 太郎", expected: "name=%E7%94%B0%E4%B8%AD%20%E5%A4%AA%E9%83%8E"},
+		{name: "Urlencode @file", kind: dataURLEncode, value: "@" + dataFile, expected: "line1%0D%0Aline2%0A"},
+		{name: "Urlencode name@file", kind: dataURLEncode, value: "text@" + dataFile, expected: "text=line1%0D%0Aline2%0A"},
+		{name: "Urlencode keeps unreserved characters", kind: dataURLEncode, value: "v=a-b_c.d~e", expected: "v=a-b_c.d~e"},
+		{name: "Missing file", kind: dataASCII, value: "@" + filepath.Join(tmpDir, "missing.txt"), hasError: true},
+		{name: "Stdin", kind: dataBinary, value: "@-", hasError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := dataValue(tt.kind, tt.value)
+
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+				return
+			}
+
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestParseCurlCommandDataFlags(t *testing.T) {
+	tests := []struct {
+		name        string
+		command     string
+		method      string
+		body        string
+		contentType string
+	}{
+		{
+			name:        "Repeated -d is joined with &",
+			command:     `curl -d a=1 -d b=2 https://api.example.com`,
+			method:      "POST",
+			body:        "a=1&b=2",
+			contentType: "application/x-www-form-urlencoded",
+		},
+		{
+			name:        "DevTools --data-raw",
+			command:     `curl 'https://api.example.com' -H 'content-type: application/json' --data-raw '{"name":"test"}'`,
+			method:      "POST",
+			body:        `{"name":"test"}`,
+			contentType: "application/json",
+		},
+		{
+			name:        "Mixed data flags",
+			command:     `curl --data-urlencode "q=a b" --data-binary x=1 https://api.example.com`,
+			method:      "POST",
+			body:        "q=a%20b&x=1",
+			contentType: "application/x-www-form-urlencoded",
+		},
+		{
+			name:        "Explicit method is kept",
+			command:     `curl -X PUT -d a=1 https://api.example.com`,
+			method:      "PUT",
+			body:        "a=1",
+			contentType: "application/x-www-form-urlencoded",
+		},
+		{
+			name:        "Empty data still sends a body",
+			command:     `curl -d "" https://api.example.com`,
+			method:      "POST",
+			body:        "",
+			contentType: "application/x-www-form-urlencoded",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := parseCurlCommand(tt.command)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if req.Method != tt.method {
+				t.Errorf("Expected method %q, got %q", tt.method, req.Method)
+			}
+			if !req.HasBody || req.Body != tt.body {
+				t.Errorf("Expected body %q, got %q (HasBody=%v)", tt.body, req.Body, req.HasBody)
+			}
+			if ct := req.Header.Get("Content-Type"); ct != tt.contentType {
+				t.Errorf("Expected Content-Type %q, got %q", tt.contentType, ct)
+			}
+		})
+	}
+
+	if _, err := parseCurlCommand(`curl -d @/nonexistent/file https://api.example.com`); err == nil {
+		t.Error("Expected error for missing data file")
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// formField is a single part of a multipart/form-data body (-F, --form-string)
+type formField struct {
+	Name        string
+	Value       string // literal value, used when File is empty
+	File        string // file whose content is sent as the value
+	Upload      bool   // "@file": sent as a file upload with a filename
+	ContentType string // ";type=" modifier
+	Filename    string // ";filename=" modifier
+}
+
+// parseFormField parses a -F value in curl's syntax:
+//
+//   - name=value sends a plain field
+//   - name=@file uploads a file
+//   - name=<file sends the content of a file as a plain field
+//
+// followed by optional ";type=" and ";filename=" modifiers. The value may be
+// double quoted to include ';' or ','. With literal set (--form-string) the
+// value is used as is.
+func parseFormField(spec string, literal bool) (formField, error) {
+	name, value, found := strings.Cut(spec, "=")
+	if !found || name == "" {
+		return formField{}, fmt.Errorf("invalid form field %q: expected name=value", spec)
+	}
+
+	field := formField{Name: name}
+	if literal {
+		field.Value = value
+		return field, nil
+	}
+
+	fromFile := false
+	switch {
+	case strings.HasPrefix(value, "@"):
+		field.Upload = true
+		fromFile = true
+		value = value[1:]
+	case strings.HasPrefix(value, "<"):
+		fromFile = true
+		value = value[1:]
+	}
+
+	content, modifiers, err := splitFormModifiers(value)
+	if err != nil {
+		return formField{}, fmt.Errorf("invalid form field %q: %w", spec, err)
+	}
+
+	for _, modifier := range modifiers {
+		key, val, _ := strings.Cut(modifier, "=")
+		switch strings.ToLower(key) {
+		case "type":
+			field.ContentType = val
+		case "filename":
+			field.Filename = val
+		default:
+			return formField{}, fmt.Errorf("invalid form field %q: unsupported modifier %q", spec, key)
+		}
+	}
+
+	if fromFile {
+		if content == "" {
+			return formField{}, fmt.Errorf("invalid form field %q: missing file name", spec)
+		}
+		if content == "-" {
+			return formField{}, fmt.Errorf("reading form data from stdin is not supported")
+		}
+		if _, err := os.Stat(content); err != nil {
+			return formField{}, fmt.Errorf("failed to open form file: %w", err)
+		}
+		field.File = content
+	} else {
+		field.Value = content
+	}
+
+	return field, nil
+}
+
+// splitFormModifiers separates a -F value from its ";key=value" modifiers.
+// A ';' only starts a modifier when it is followed by a known key, so plain
+// values may contain semicolons.
+func splitFormModifiers(value string) (string, []string, error) {
+	var content string
+	rest := value
+
+	if strings.HasPrefix(value, `"`) {
+		var b strings.Builder
+		i := 1
+		for ; i < len(value) && value[i] != '"'; i++ {
+			if value[i] == '\\' && i+1 < len(value) {
+				i++
+			}
+			b.WriteByte(value[i])
+		}
+		if i >= len(value) {
+			return "", nil, fmt.Errorf("unclosed quote")
+		}
+		content = b.String()
+		rest = value[i+1:]
+		if rest != "" && rest[0] != ';' {
+			return "", nil, fmt.Errorf("unexpected %q after quoted value", rest)
+		}
+	} else {
+		end := modifierStart(value)
+		content = value[:end]
+		rest = value[end:]
+	}
+
+	var modifiers []string
+	for rest != "" {
+		rest = rest[1:]
+		end := modifierStart(rest)
+		modifiers = append(modifiers, rest[:end])
+		rest = rest[end:]
+	}
+
+	return content, modifiers, nil
+}
+
+// modifierStart returns the index of the first ";type=" or ";filename="
+// in s, or len(s)
+func modifierStart(s string) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] != ';' {
+			continue
+		}
+		next := strings.ToLower(s[i+1:])
+		if strings.HasPrefix(next, "type=") || strings.HasPrefix(next, "filename=") {
+			return i
+		}
+	}
+	return len(s)
+}
+
+// multipartBody returns a reader that streams the form as a multipart body,
+// along with its Content-Type. Files are read from disk while the body is
+// sent, so a new body must be created for every attempt.
+func multipartBody(fields []formField) (io.ReadCloser, string) {
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+
+	go func() {
+		err := writeFormFields(writer, fields)
+		if err == nil {
+			err = writer.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	return pr, writer.FormDataContentType()
+}
+
+// writeFormFields writes every field as a part of the multipart body
+func writeFormFields(writer *multipart.Writer, fields []formField) error {
+	for _, field := range fields {
+		header := make(textproto.MIMEHeader)
+		disposition := fmt.Sprintf(`form-data; name="%s"`, escapeQuotes(field.Name))
+
+		filename := field.Filename
+		if filename == "" && field.Upload {
+			filename = filepath.Base(field.File)
+		}
+		if filename != "" {
+			disposition += fmt.Sprintf(`; filename="%s"`, escapeQuotes(filename))
+		}
+		header.Set("Content-Disposition", disposition)
+
+		contentType := field.ContentType
+		if contentType == "" && field.Upload {
+			contentType = mime.TypeByExtension(filepath.Ext(field.File))
+			if contentType == "" {
+				contentType = "application/octet-stream"
+			}
+		}
+		if contentType != "" {
+			header.Set("Content-Type", contentType)
+		}
+
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return err
+		}
+
+		if field.File == "" {
+			if _, err := io.WriteString(part, field.Value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := copyFile(part, field.File); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyFile streams the content of a file to w
+func copyFile(w io.Writer, filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("failed to open form file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(w, f); err != nil {
+		return fmt.Errorf("failed to read form file: %w", err)
+	}
+	return nil
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// escapeQuotes escapes a value for use in a Content-Disposition parameter
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFormField(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "curl_batch_test_*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	file := filepath.Join(tempDir, "report.pdf")
+	if err := os.WriteFile(file, []byte("%PDF"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		spec     string
+		literal  bool
+		expected formField
+		hasError bool
+	}{
+		{
+			name:     "Plain value",
+			spec:     "name=田中",
+			expected: formField{Name: "name", Value: "田中"},
+		},
+		{
+			name:     "Value with semicolon",
+			spec:     "q=a;b",
+			expected: formField{Name: "q", Value: "a;b"},
+		},
+		{
+			name:     "Quoted value",
+			spec:     `note="a;type=b \"c\""`,
+			expected: formField{Name: "note", Value: `a;type=b "c"`},
+		},
+		{
+			name:     "Value with type",
+			spec:     `meta={"a":1};type=application/json`,
+			expected: formField{Name: "meta", Value: `{"a":1}`, ContentType: "application/json"},
+		},
+		{
+			name:     "File upload",
+			spec:     "file=@" + file,
+			expected: formField{Name: "file", File: file, Upload: true},
+		},
+		{
+			name:     "File upload with modifiers",
+			spec:     "file=@" + file + ";type=application/pdf;filename=invoice.pdf",
+			expected: formField{Name: "file", File: file, Upload: true, ContentType: "application/pdf", Filename: "invoice.pdf"},
+		},
+		{
+			name:     "File content as value",
+			spec:     "body=<" + file,
+			expected: formField{Name: "body", File: file},
+		},
+		{
+			name:     "Form string is literal",
+			spec:     "file=@" + file + ";type=text/plain",
+			literal:  true,
+			expected: formField{Name: "file", Value: "@" + file + ";type=text/plain"},
+		},
+		{
+			name:     "Missing name",
+			spec:     "=value",
+			hasError: true,
+		},
+		{
+			name:     "Missing file",
+			spec:     "file=@" + filepath.Join(tempDir, "missing.pdf"),
+			hasError: true,
+		},
+		{
+			name:     "Unclosed quote",
+			spec:     `note="abc`,
+			hasError: true,
+		},
+		{
+			name:     "Stdin",
+			spec:     "file=@-",
+			hasError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parseFormField(tt.spec, tt.literal)
+
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestMultipartRequest(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "curl_batch_test_*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	file := filepath.Join(tempDir, "doc.txt")
+	if err := os.WriteFile(file, []byte("hello\nworld\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	type part struct {
+		filename    string
+		contentType string
+		content     string
+	}
+	var method string
+	parts := make(map[string]part)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		reader, err := r.MultipartReader()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for {
+			p, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			content, _ := io.ReadAll(p)
+			parts[p.FormName()] = part{p.FileName(), p.Header.Get("Content-Type"), string(content)}
+		}
+	}))
+	defer server.Close()
+
+	cb := &CurlBatch{}
+	req := mustParseCurlCommand(t, `curl -F "name=Tanaka" -F "doc=@`+file+`;type=text/plain" -F "copy=<`+file+`" `+server.URL)
+
+	// The body is rebuilt for every attempt, so sending twice must work
+	for range 2 {
+		resp, err := cb.executeRequest(req)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", resp.StatusCode, resp.Body)
+		}
+	}
+
+	if method != "POST" {
+		t.Errorf("Expected POST, got %s", method)
+	}
+
+	expected := map[string]part{
+		"name": {"", "", "Tanaka"},
+		"doc":  {"doc.txt", "text/plain", "hello\nworld\n"},
+		"copy": {"", "", "hello\nworld\n"},
+	}
+	if !reflect.DeepEqual(parts, expected) {
+		t.Errorf("Expected parts %+v, got %+v", expected, parts)
+	}
+}
+
+func TestFormWithDataIsRejected(t *testing.T) {
+	_, err := parseCurlCommand(`curl -F a=1 -d b=2 https://api.example.com`)
+	if err == nil || !strings.Contains(err.Error(), "cannot be combined") {
+		t.Errorf("Expected error about combining -F and -d, got %v", err)
+	}
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// curlOption describes a curl command line option. Options are looked up by
+// their long name ("--request") or their short name ("-X").
+type curlOption struct {
+	long   string
+	short  byte
+	hasArg bool
+	apply  func(p *curlParser, value string) error
+}
+
+// curlParser holds the state built up while parsing curl options
+type curlParser struct {
+	req  *curlRequest
+	data []string
+	get  bool
+}
+
+// curlOptions lists every curl option curl-batch understands.
+// Options that only affect curl's own terminal output are accepted and ignored.
+var curlOptions = []curlOption{
+	{long: "request", short: 'X', hasArg: true, apply: func(p *curlParser, value string) error {
+		p.req.Method = value
+		return nil
+	}},
+	{long: "header", short: 'H', hasArg: true, apply: func(p *curlParser, value string) error {
+		return addHeader(p.req.Header, value)
+	}},
+	{long: "url", hasArg: true, apply: func(p *curlParser, value string) error {
+		return p.setURL(value)
+	}},
+	{long: "data", short: 'd', hasArg: true, apply: dataOption(dataASCII)},
+	{long: "data-ascii", hasArg: true, apply: dataOption(dataASCII)},
+	{long: "data-raw", hasArg: true, apply: dataOption(dataRaw)},
+	{long: "data-binary", hasArg: true, apply: dataOption(dataBinary)},
+	{long: "data-urlencode", hasArg: true, apply: dataOption(dataURLEncode)},
+	{long: "form", short: 'F', hasArg: true, apply: formOption(false)},
+	{long: "form-string", hasArg: true, apply: formOption(true)},
+	{long: "user", short: 'u', hasArg: true, apply: func(p *curlParser, value string) error {
+		user, password, err := parseUserOption(value)
+		if err != nil {
+			return err
+		}
+		p.req.User, p.req.Password = user, password
+		return nil
+	}},
+	{long: "basic", apply: func(p *curlParser, value string) error {
+		p.req.AuthScheme = authBasic
+		return nil
+	}},
+	{long: "digest", apply: func(p *curlParser, value string) error {
+		p.req.AuthScheme = authDigest
+		return nil
+	}},
+	{long: "oauth2-bearer", hasArg: true, apply: func(p *curlParser, value string) error {
+		token, err := expandEnv(value)
+		if err != nil {
+			return err
+		}
+		p.req.BearerToken = token
+		return nil
+	}},
+	{long: "insecure", short: 'k', apply: func(p *curlParser, value string) error {
+		p.req.TLS.Insecure = true
+		return nil
+	}},
+	{long: "cacert", hasArg: true, apply: func(p *curlParser, value string) error {
+		p.req.TLS.CACert = value
+		return nil
+	}},
+	{long: "capath", hasArg: true, apply: func(p *curlParser, value string) error {
+		p.req.TLS.CAPath = value
+		return nil
+	}},
+	{long: "cert", short: 'E', hasArg: true, apply: func(p *curlParser, value string) error {
+		p.req.TLS.Cert = value
+		return nil
+	}},
+	{long: "key", hasArg: true, apply: func(p *curlParser, value string) error {
+		p.req.TLS.Key = value
+		return nil
+	}},
+	{long: "tlsv1.2", apply: func(p *curlParser, value string) error {
+		p.req.TLS.MinVersion = tls.VersionTLS12
+		return nil
+	}},
+	{long: "tlsv1.3", apply: func(p *curlParser, value string) error {
+		p.req.TLS.MinVersion = tls.VersionTLS13
+		return nil
+	}},
+	{long: "resolve", hasArg: true, apply: func(p *curlParser, value string) error {
+		hostPort, address, err := parseResolve(value)
+		if err != nil {
+			return err
+		}
+		if p.req.Resolve == nil {
+			p.req.Resolve = make(map[string]string)
+		}
+		p.req.Resolve[hostPort] = address
+		return nil
+	}},
+	{long: "proxy", short: 'x', hasArg: true, apply: func(p *curlParser, value string) error {
+		proxyURL, err := parseProxyURL(value)
+		if err != nil {
+			return err
+		}
+		p.req.Proxy = proxyURL
+		return nil
+	}},
+	{long: "proxy-user", short: 'U', hasArg: true, apply: func(p *curlParser, value string) error {
+		user, err := parseProxyUser(value)
+		if err != nil {
+			return err
+		}
+		p.req.ProxyUser = user
+		return nil
+	}},
+	{long: "noproxy", hasArg: true, apply: func(p *curlParser, value string) error {
+		p.req.NoProxy = value
+		return nil
+	}},
+	{long: "connect-timeout", hasArg: true, apply: func(p *curlParser, value string) error {
+		timeout, err := parseSeconds(value)
+		if err != nil {
+			return err
+		}
+		p.req.ConnectTimeout = &timeout
+		return nil
+	}},
+	{long: "max-time", short: 'm', hasArg: true, apply: func(p *curlParser, value string) error {
+		timeout, err := parseSeconds(value)
+		if err != nil {
+			return err
+		}
+		p.req.MaxTime = &timeout
+		return nil
+	}},
+	{long: "get", short: 'G', apply: func(p *curlParser, value string) error {
+		p.get = true
+		return nil
+	}},
+	{long: "head", short: 'I', apply: func(p *curlParser, value string) error {
+		p.req.Method = http.MethodHead
+		return nil
+	}},
+	{long: "user-agent", short: 'A', hasArg: true, apply: func(p *curlParser, value string) error {
+		p.req.Header.Set("User-Agent", value)
+		return nil
+	}},
+	{long: "referer", short: 'e', hasArg: true, apply: func(p *curlParser, value string) error {
+		p.req.Header.Set("Referer", value)
+		return nil
+	}},
+	{long: "location", short: 'L', apply: func(p *curlParser, value string) error {
+		p.req.FollowRedirects = true
+		return nil
+	}},
+	{long: "max-redirs", hasArg: true, apply: func(p *curlParser, value string) error {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < -1 {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		p.req.MaxRedirs = &limit
+		return nil
+	}},
+	{long: "post301", apply: keepPostOption(http.StatusMovedPermanently)},
+	{long: "post302", apply: keepPostOption(http.StatusFound)},
+	{long: "post303", apply: keepPostOption(http.StatusSeeOther)},
+	{long: "cookie", short: 'b', hasArg: true, apply: func(p *curlParser, value string) error {
+		// Like curl, a value with "=" is sent as is; anything else is a file
+		if strings.Contains(value, "=") {
+			if p.req.Cookie != "" {
+				p.req.Cookie += "; "
+			}
+			p.req.Cookie += value
+		} else {
+			p.req.CookieFiles = append(p.req.CookieFiles, value)
+		}
+		return nil
+	}},
+	{long: "cookie-jar", short: 'c', hasArg: true, apply: func(p *curlParser, value string) error {
+		p.req.CookieJarFile = value
+		return nil
+	}},
+	{long: "http1.0", short: '0', apply: httpVersionOption(http10)},
+	{long: "http1.1", apply: httpVersionOption(http11)},
+	{long: "http2", apply: httpVersionOption(http2)},
+	{long: "http2-prior-knowledge", apply: httpVersionOption(http2PriorKnowledge)},
+	{long: "compressed", apply: func(p *curlParser, value string) error {
+		p.req.Compressed = true
+		return nil
+	}},
+	{long: "silent", short: 's', apply: ignoreOption},
+	{long: "show-error", short: 'S', apply: ignoreOption},
+	{long: "verbose", short: 'v', apply: ignoreOption},
+	{long: "include", short: 'i', apply: ignoreOption},
+	{long: "globoff", short: 'g', apply: ignoreOption},
+	{long: "no-progress-meter", apply: ignoreOption},
+}
+
+// ignoreOption accepts an option that has no effect on the request
+func ignoreOption(p *curlParser, value string) error {
+	return nil
+}
+
+// dataOption returns the apply function for one of the data flags
+func dataOption(kind string) func(p *curlParser, value string) error {
+	return func(p *curlParser, value string) error {
+		data, err := dataValue(kind, value)
+		if err != nil {
+			return err
+		}
+		p.data = append(p.data, data)
+		return nil
+	}
+}
+
+// formOption returns the apply function for -F/--form and --form-string
+func formOption(literal bool) func(p *curlParser, value string) error {
+	return func(p *curlParser, value string) error {
+		field, err := parseFormField(value, literal)
+		if err != nil {
+			return err
+		}
+		p.req.Form = append(p.req.Form, field)
+		return nil
+	}
+}
+
+// keepPostOption returns the apply function for --post301, --post302 and --post303
+func keepPostOption(code int) func(p *curlParser, value string) error {
+	return func(p *curlParser, value string) error {
+		if p.req.KeepPost == nil {
+			p.req.KeepPost = make(map[int]bool)
+		}
+		p.req.KeepPost[code] = true
+		return nil
+	}
+}
+
+// lookupLongOption returns the option with the given long name, or nil
+func lookupLongOption(name string) *curlOption {
+	for i := range curlOptions {
+		if curlOptions[i].long == name {
+			return &curlOptions[i]
+		}
+	}
+	return nil
+}
+
+// lookupShortOption returns the option with the given short name, or nil
+func lookupShortOption(name byte) *curlOption {
+	for i := range curlOptions {
+		if curlOptions[i].short == name {
+			return &curlOptions[i]
+		}
+	}
+	return nil
+}
+
+// name returns the name of the option as written on the command line
+func (o *curlOption) name() string {
+	if o.short != 0 {
+		return fmt.Sprintf("-%c/--%s", o.short, o.long)
+	}
+	return "--" + o.long
+}
+
+// parseArgs parses the arguments following "curl". Options may be given in
+// long form (--request POST or --request=POST), in short form (-X POST or
+// -XPOST) and as combined short flags (-sSL). Any option that is not in
+// curlOptions is reported in a single error.
+func (p *curlParser) parseArgs(args []string) error {
+	var unsupported, urls []string
+	endOfOptions := false
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		switch {
+		case endOfOptions || arg == "-" || !strings.HasPrefix(arg, "-"):
+			urls = append(urls, arg)
+
+		case arg == "--":
+			endOfOptions = true
+
+		case strings.HasPrefix(arg, "--"):
+			name, value, hasValue := strings.Cut(arg[2:], "=")
+			opt := lookupLongOption(name)
+			if opt == nil {
+				unsupported = append(unsupported, "--"+name)
+				continue
+			}
+
+			if opt.hasArg && !hasValue {
+				if i+1 >= len(args) {
+					return fmt.Errorf("option %s requires an argument", opt.name())
+				}
+				i++
+				value = args[i]
+			} else if !opt.hasArg && hasValue {
+				return fmt.Errorf("option %s does not take an argument", opt.name())
+			}
+
+			if err := opt.apply(p, value); err != nil {
+				return fmt.Errorf("option %s: %w", opt.name(), err)
+			}
+
+		default:
+			// One or more short options, the last of which may take an
+			// argument either attached (-XPOST) or as the next word
+			for j := 1; j < len(arg); j++ {
+				opt := lookupShortOption(arg[j])
+				if opt == nil {
+					unsupported = append(unsupported, "-"+string(arg[j]))
+					break
+				}
+
+				if !opt.hasArg {
+					if err := opt.apply(p, ""); err != nil {
+						return fmt.Errorf("option %s: %w", opt.name(), err)
+					}
+					continue
+				}
+
+				value := arg[j+1:]
+				if value == "" {
+					if i+1 >= len(args) {
+						return fmt.Errorf("option %s requires an argument", opt.name())
+					}
+					i++
+					value = args[i]
+				}
+				if err := opt.apply(p, value); err != nil {
+					return fmt.Errorf("option %s: %w", opt.name(), err)
+				}
+				break
+			}
+		}
+	}
+
+	// Whether an unknown option takes an argument is unknown, so its
+	// argument ends up among the URLs; report the option rather than a
+	// confusing second URL
+	if len(unsupported) > 0 {
+		return fmt.Errorf("unsupported curl options: %s", strings.Join(unsupported, ", "))
+	}
+
+	for _, url := range urls {
+		if err := p.setURL(url); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setURL sets the request URL. curl-batch sends a single request per row,
+// so only one URL may be given. Like curl, a URL without a scheme is
+// assumed to be http.
+func (p *curlParser) setURL(url string) error {
+	if p.req.URL != "" {
+		return fmt.Errorf("multiple URLs are not supported: %s and %s", p.req.URL, url)
+	}
+	if !strings.Contains(url, "://") {
+		url = "http://" + url
+	}
+	p.req.URL = url
+	return nil
+}
+
+// addHeader adds a header given in curl's -H syntax. As in curl,
+// "Name:" removes the header and "Name;" sends it with an empty value.
+func addHeader(header http.Header, value string) error {
+	if name, ok := strings.CutSuffix(strings.TrimSpace(value), ";"); ok && !strings.Contains(name, ":") {
+		header.Add(strings.TrimSpace(name), "")
+		return nil
+	}
+
+	name, val, found := strings.Cut(value, ":")
+	name = strings.TrimSpace(name)
+	if !found || name == "" {
+		return fmt.Errorf("invalid header %q", value)
+	}
+
+	val = strings.TrimSpace(val)
+	if val == "" {
+		header.Del(name)
+		return nil
+	}
+	header.Add(name, val)
+	return nil
+}
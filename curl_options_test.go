@@ -0,0 +1,207 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCurlOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  string
+		expected *curlRequest
+	}{
+		{
+			name:    "Long form options",
+			command: `curl --request PUT --header "X-Id: 1" --data-raw 'a=1' --url https://api.example.com`,
+			expected: &curlRequest{
+				Method:  "PUT",
+				URL:     "https://api.example.com",
+				Header:  http.Header{"X-Id": {"1"}, "Content-Type": {"application/x-www-form-urlencoded"}},
+				Body:    "a=1",
+				HasBody: true,
+			},
+		},
+		{
+			name:    "Long options with equals",
+			command: `curl --request=DELETE --user-agent=batch/1.0 --url=https://api.example.com/items/1`,
+			expected: &curlRequest{
+				Method: "DELETE",
+				URL:    "https://api.example.com/items/1",
+				Header: http.Header{"User-Agent": {"batch/1.0"}},
+			},
+		},
+		{
+			name:    "Combined short flags",
+			command: `curl -sSL https://api.example.com`,
+			expected: &curlRequest{
+				Method:          "GET",
+				URL:             "https://api.example.com",
+				Header:          http.Header{},
+				FollowRedirects: true,
+			},
+		},
+		{
+			name:    "Attached short value",
+			command: `curl -XPATCH -HX-Id:2 https://api.example.com`,
+			expected: &curlRequest{
+				Method: "PATCH",
+				URL:    "https://api.example.com",
+				Header: http.Header{"X-Id": {"2"}},
+			},
+		},
+		{
+			name:    "Combined flags ending in option with value",
+			command: `curl -sLXPOST https://api.example.com`,
+			expected: &curlRequest{
+				Method:          "POST",
+				URL:             "https://api.example.com",
+				Header:          http.Header{},
+				FollowRedirects: true,
+			},
+		},
+		{
+			name:    "Location and compressed",
+			command: `curl --location --compressed -A agent https://api.example.com`,
+			expected: &curlRequest{
+				Method:          "GET",
+				URL:             "https://api.example.com",
+				Header:          http.Header{"User-Agent": {"agent"}, "Accept-Encoding": {acceptEncoding}},
+				FollowRedirects: true,
+				Compressed:      true,
+			},
+		},
+		{
+			name:    "URL without scheme",
+			command: `curl example.com/path`,
+			expected: &curlRequest{
+				Method: "GET",
+				URL:    "http://example.com/path",
+				Header: http.Header{},
+			},
+		},
+		{
+			name:    "Data with -G goes to the query string",
+			command: `curl -G -d a=1 --data-urlencode 'q=x y' https://api.example.com/search?p=1`,
+			expected: &curlRequest{
+				Method: "GET",
+				URL:    "https://api.example.com/search?p=1&a=1&q=x%20y",
+				Header: http.Header{},
+			},
+		},
+		{
+			name:    "HEAD request",
+			command: `curl -I https://api.example.com`,
+			expected: &curlRequest{
+				Method: "HEAD",
+				URL:    "https://api.example.com",
+				Header: http.Header{},
+			},
+		},
+		{
+			name:    "Repeated and removed headers",
+			command: `curl -H "X-A: 1" -H "X-A: 2" -H "Content-Type:" -H "X-Empty;" -d x https://api.example.com`,
+			expected: &curlRequest{
+				Method:  "POST",
+				URL:     "https://api.example.com",
+				Header:  http.Header{"X-A": {"1", "2"}, "X-Empty": {""}, "Content-Type": {"application/x-www-form-urlencoded"}},
+				Body:    "x",
+				HasBody: true,
+			},
+		},
+		{
+			name:    "End of options",
+			command: `curl -X GET -- https://api.example.com`,
+			expected: &curlRequest{
+				Method: "GET",
+				URL:    "https://api.example.com",
+				Header: http.Header{},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parseCurlCommand(tt.command)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestParseCurlOptionErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		errText string
+	}{
+		{
+			name:    "Unsupported options are all listed",
+			command: `curl --foo -Z --bar=1 https://api.example.com`,
+			errText: "unsupported curl options: --foo, -Z, --bar",
+		},
+		{
+			name:    "Unsupported short option with an argument",
+			command: `curl -o out.txt https://api.example.com`,
+			errText: "unsupported curl options: -o",
+		},
+		{
+			name:    "Unsupported long option with an argument",
+			command: `curl --output out.txt https://api.example.com`,
+			errText: "unsupported curl options: --output",
+		},
+		{
+			name:    "Unsupported write-out format",
+			command: `curl -w '%{http_code}' https://api.example.com`,
+			errText: "unsupported curl options: -w",
+		},
+		{
+			name:    "Unsupported option before the URL",
+			command: `curl --retry 3 https://api.example.com`,
+			errText: "unsupported curl options: --retry",
+		},
+		{
+			name:    "Missing argument",
+			command: `curl https://api.example.com -X`,
+			errText: "option -X/--request requires an argument",
+		},
+		{
+			name:    "Value given to flag",
+			command: `curl --location=yes https://api.example.com`,
+			errText: "option -L/--location does not take an argument",
+		},
+		{
+			name:    "No URL",
+			command: `curl -X POST`,
+			errText: "no URL specified",
+		},
+		{
+			name:    "Multiple URLs",
+			command: `curl https://a.example.com https://b.example.com`,
+			errText: "multiple URLs are not supported",
+		},
+		{
+			name:    "Invalid header",
+			command: `curl -H "no colon" https://api.example.com`,
+			errText: "invalid header",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseCurlCommand(tt.command)
+			if err == nil {
+				t.Fatalf("Expected error containing %q but got none", tt.errText)
+			}
+			if !strings.Contains(err.Error(), tt.errText) {
+				t.Errorf("Expected error containing %q, got %q", tt.errText, err.Error())
+			}
+		})
+	}
+}
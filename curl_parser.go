@@ -1,143 +1,542 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"net/http/httptrace"
+	"net/url"
 	"strings"
 	"time"
 )
 
-// splitCurlCommand parses a curl command string and splits it into arguments
-// while properly handling quoted strings (both single and double quotes)
+// splitCurlCommand splits a curl command into arguments following POSIX
+// shell word splitting:
+//
+//   - words are separated by spaces, tabs and (with CRLF files) carriage returns
+//   - a backslash followed by a newline continues the command on the next line
+//   - a '#' at the start of a word begins a comment that runs to the end of the line
+//   - single quotes, double quotes and $'...' (ANSI-C quoting) delimit regions
+//     within a word, so adjacent quoted and unquoted segments form one word
+//   - outside quotes a backslash makes the next character literal; inside
+//     quotes it follows the rules of readSingleQuoted and readDoubleQuoted
+//
+// Variables and command substitutions are not expanded: $NAME and `...` are
+// kept literally, as template variables are replaced before splitting.
+//
+// A template holds a single command, so a newline that is not escaped with a
+// backslash may only be followed by blank or comment lines.
 func splitCurlCommand(command string) ([]string, error) {
 	var parts []string
 	var current strings.Builder
-	inQuotes := false
-	quoteChar := byte(0)
-	escaped := false
+	inWord := false
+	ended := false
 
 	for i := 0; i < len(command); i++ {
 		char := command[i]
 
-		if escaped {
+		if ended && char != ' ' && char != '\t' && char != '\r' && char != '\n' && char != '#' {
+			return nil, fmt.Errorf("unexpected text after the end of the command on line %d; use \\ to continue a line",
+				strings.Count(command[:i], "\n")+1)
+		}
+
+		switch {
+		case char == ' ' || char == '\t' || char == '\r' || char == '\n':
+			if inWord {
+				parts = append(parts, current.String())
+				current.Reset()
+				inWord = false
+			}
+			if char == '\n' && len(parts) > 0 {
+				ended = true
+			}
+
+		case char == '#' && !inWord:
+			for i+1 < len(command) && command[i+1] != '\n' {
+				i++
+			}
+
+		case char == '\\':
+			if next, size := lineContinuation(command[i+1:]); size > 0 {
+				i += size
+			} else if i+1 < len(command) {
+				current.WriteByte(next)
+				i++
+				inWord = true
+			} else {
+				current.WriteByte(char)
+				inWord = true
+			}
+
+		case char == '\'':
+			end, err := readSingleQuoted(command, i+1, &current)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+			inWord = true
+
+		case char == '"':
+			end, err := readDoubleQuoted(command, i+1, &current)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+			inWord = true
+
+		case char == '$' && i+1 < len(command) && command[i+1] == '\'':
+			end, err := readANSICQuoted(command, i+2, &current)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+			inWord = true
+
+		default:
 			current.WriteByte(char)
-			escaped = false
-			continue
+			inWord = true
 		}
+	}
 
-		if char == '\\' {
-			escaped = true
-			continue
+	if inWord {
+		parts = append(parts, current.String())
+	}
+
+	return parts, nil
+}
+
+// lineContinuation reports whether s, the text following a backslash,
+// starts with a newline (LF or CRLF). It returns the byte following the
+// backslash and the length of the newline, or 0 if there is none.
+func lineContinuation(s string) (byte, int) {
+	switch {
+	case strings.HasPrefix(s, "\n"):
+		return '\n', 1
+	case strings.HasPrefix(s, "\r\n"):
+		return '\r', 2
+	case s == "":
+		return 0, 0
+	default:
+		return s[0], 0
+	}
+}
+
+// readSingleQuoted appends the content of a single quoted region starting at
+// index start to b and returns the index of the closing quote. As in the
+// shell, every character up to the closing quote is literal, backslashes
+// included.
+func readSingleQuoted(command string, start int, b *strings.Builder) (int, error) {
+	end := strings.IndexByte(command[start:], '\'')
+	if end < 0 {
+		return 0, fmt.Errorf("unclosed quote in command")
+	}
+	b.WriteString(command[start : start+end])
+	return start + end, nil
+}
+
+// readDoubleQuoted appends the content of a double quoted region starting at
+// index start to b and returns the index of the closing quote. Inside double
+// quotes a backslash only escapes $, `, ", \ and newline; before any other
+// character it is kept as is.
+func readDoubleQuoted(command string, start int, b *strings.Builder) (int, error) {
+	for i := start; i < len(command); i++ {
+		char := command[i]
+		switch char {
+		case '"':
+			return i, nil
+		case '\\':
+			if _, size := lineContinuation(command[i+1:]); size > 0 {
+				i += size
+				continue
+			}
+			if i+1 < len(command) && strings.IndexByte("$`\"\\", command[i+1]) >= 0 {
+				i++
+				char = command[i]
+			}
 		}
+		b.WriteByte(char)
+	}
+	return 0, fmt.Errorf("unclosed quote in command")
+}
 
-		if !inQuotes && (char == '\'' || char == '"') {
-			inQuotes = true
-			quoteChar = char
+// ansiCEscapes maps the single character escapes of $'...' quoting
+var ansiCEscapes = map[byte]byte{
+	'a': '\a', 'b': '\b', 'e': 0x1b, 'E': 0x1b, 'f': '\f', 'n': '\n', 'r': '\r',
+	't': '\t', 'v': '\v', '\\': '\\', '\'': '\'', '"': '"', '?': '?',
+}
+
+// readANSICQuoted appends the content of a $'...' region starting at index
+// start (just after the opening quote) to b, expanding backslash escapes the
+// way bash does, and returns the index of the closing quote
+func readANSICQuoted(command string, start int, b *strings.Builder) (int, error) {
+	for i := start; i < len(command); i++ {
+		char := command[i]
+		if char == '\'' {
+			return i, nil
+		}
+		if char != '\\' || i+1 >= len(command) {
+			b.WriteByte(char)
 			continue
 		}
 
-		if inQuotes && char == quoteChar {
-			inQuotes = false
-			quoteChar = 0
-			// Always add the current content when closing quotes (even if empty)
-			parts = append(parts, current.String())
-			current.Reset()
+		i++
+		esc := command[i]
+		if c, ok := ansiCEscapes[esc]; ok {
+			b.WriteByte(c)
 			continue
 		}
 
-		if !inQuotes && (char == ' ' || char == '\t') {
-			if current.Len() > 0 {
-				parts = append(parts, current.String())
-				current.Reset()
+		switch esc {
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			value, n := parseDigits(command[i:], 8, 3)
+			b.WriteByte(byte(value))
+			i += n - 1
+		case 'x', 'u', 'U':
+			maxDigits := map[byte]int{'x': 2, 'u': 4, 'U': 8}[esc]
+			value, n := parseDigits(command[i+1:], 16, maxDigits)
+			if n == 0 {
+				b.WriteByte('\\')
+				b.WriteByte(esc)
+				continue
 			}
-			continue
+			if esc == 'x' {
+				b.WriteByte(byte(value))
+			} else {
+				b.WriteRune(rune(value))
+			}
+			i += n
+		case 'c':
+			if i+1 < len(command) {
+				i++
+				b.WriteByte(command[i] & 0x1f)
+			}
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(esc)
 		}
+	}
+	return 0, fmt.Errorf("unclosed quote in command")
+}
 
-		current.WriteByte(char)
+// parseDigits parses up to maxDigits digits in the given base at the start
+// of s and returns the value and the number of digits consumed
+func parseDigits(s string, base, maxDigits int) (int, int) {
+	value, n := 0, 0
+	for n < maxDigits && n < len(s) {
+		digit := strings.IndexByte("0123456789abcdef", lower(s[n]))
+		if digit < 0 || digit >= base {
+			break
+		}
+		value = value*base + digit
+		n++
 	}
+	return value, n
+}
 
-	if inQuotes {
-		return nil, fmt.Errorf("unclosed quote in command")
+// lower returns the lowercase form of an ASCII letter
+func lower(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
 	}
+	return c
+}
 
-	if current.Len() > 0 {
-		parts = append(parts, current.String())
+// httpResponse holds the parts of an HTTP response that are recorded in the output
+type httpResponse struct {
+	Status      string
+	StatusCode  int
+	Header      http.Header
+	Body        []byte
+	Duration    time.Duration
+	URL         string        // the URL of the final request
+	Redirects   []redirectHop // redirects followed with -L/--location
+	HTTPVersion string        // the negotiated protocol, see responseHTTPVersion
+	Timing      requestTiming
+}
+
+// String formats the response the way it is written to the output file
+func (r *httpResponse) String() string {
+	var protocol string
+	if r.HTTPVersion != "" {
+		protocol = "Protocol: HTTP/" + r.HTTPVersion + "\n"
 	}
+	return fmt.Sprintf("Status: %s\n%sHeaders: %v\nBody: %s", r.Status, protocol, r.Header, string(r.Body))
+}
 
-	return parts, nil
+// networkError is returned by executeRequest when the request could not be
+// completed because of a transport failure, as opposed to a problem with the
+// curl command itself. Only network errors are worth retrying.
+type networkError struct {
+	err error
+}
+
+func (e *networkError) Error() string {
+	return e.err.Error()
+}
+
+func (e *networkError) Unwrap() error {
+	return e.err
 }
 
-// executeRequest parses and executes a curl command
-func (cb *CurlBatch) executeRequest(curlCommand string) (string, error) {
+// curlRequest is the HTTP request described by a curl command
+type curlRequest struct {
+	Method  string
+	URL     string
+	Header  http.Header
+	Body    string
+	HasBody bool        // set by any data flag, even when Body is empty
+	Form    []formField // multipart/form-data fields, mutually exclusive with Body
+
+	User        string // -u/--user
+	Password    string
+	AuthScheme  string // authBasic (default) or authDigest
+	BearerToken string // --oauth2-bearer
+
+	TLS     tlsOptions
+	Resolve map[string]string // --resolve, "host:port" to the address to connect to
+
+	Proxy     *url.URL      // -x/--proxy
+	ProxyUser *url.Userinfo // --proxy-user
+	NoProxy   string        // --noproxy
+
+	ConnectTimeout *time.Duration // --connect-timeout, 0 for no limit; nil uses the batch default
+	MaxTime        *time.Duration // -m/--max-time, 0 for no limit; nil uses the batch default
+
+	FollowRedirects bool         // -L/--location
+	MaxRedirs       *int         // --max-redirs, -1 for no limit; nil uses defaultMaxRedirs
+	KeepPost        map[int]bool // --post301, --post302, --post303
+	Compressed      bool         // --compressed
+	HTTPVersion     string       // http10, http11, http2 or http2PriorKnowledge; empty for the batch default
+
+	Cookie        string     // -b/--cookie given as "name=value" pairs
+	CookieFiles   []string   // -b/--cookie given as a cookie file
+	CookieJarFile string     // -c/--cookie-jar
+	Jar           *CookieJar // set by the batch when the cookie engine is on
+}
+
+// hasCredentials reports whether -u/--user was given
+func (r *curlRequest) hasCredentials() bool {
+	return r.User != "" || r.Password != ""
+}
+
+// parseCurlCommand parses a curl command into the request it describes
+func parseCurlCommand(curlCommand string) (*curlRequest, error) {
 	parts, err := splitCurlCommand(curlCommand)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse curl command: %w", err)
+		return nil, fmt.Errorf("failed to parse curl command: %w", err)
 	}
 
-	if len(parts) < 2 || parts[0] != "curl" {
-		return "", fmt.Errorf("invalid curl command: %s", curlCommand)
+	if len(parts) == 0 || parts[0] != "curl" {
+		return nil, fmt.Errorf("invalid curl command: %s", curlCommand)
 	}
 
-	var method, url, body string
-	var headers []string
+	p := &curlParser{req: &curlRequest{Header: make(http.Header)}}
+	if err := p.parseArgs(parts[1:]); err != nil {
+		return nil, err
+	}
+	if p.req.URL == "" {
+		return nil, fmt.Errorf("no URL specified in curl command: %s", curlCommand)
+	}
 
-	for i := 1; i < len(parts); i++ {
-		switch parts[i] {
-		case "-X":
-			if i+1 < len(parts) {
-				method = parts[i+1]
-				i++
-			}
-		case "-H":
-			if i+1 < len(parts) {
-				headers = append(headers, parts[i+1])
-				i++
-			}
-		case "-d":
-			if i+1 < len(parts) {
-				body = parts[i+1]
-				i++
-			}
-		default:
-			if strings.HasPrefix(parts[i], "http") {
-				url = parts[i]
-			}
+	req := p.req
+	data := p.data
+
+	if len(req.Form) > 0 {
+		if len(data) > 0 {
+			return nil, fmt.Errorf("-F/--form cannot be combined with data options")
+		}
+		if req.Method == "" {
+			req.Method = "POST"
+		}
+	}
+
+	// With -G the data is appended to the URL as a query string instead
+	if p.get && len(data) > 0 {
+		separator := "?"
+		if strings.Contains(req.URL, "?") {
+			separator = "&"
 		}
+		req.URL += separator + strings.Join(data, "&")
+		data = nil
 	}
 
-	if method == "" {
-		method = "GET"
+	// Like curl, repeated data flags are joined with "&" and sent as a
+	// form POST unless the method and content type are given explicitly
+	if len(data) > 0 {
+		req.Body = strings.Join(data, "&")
+		req.HasBody = true
+		if req.Method == "" {
+			req.Method = "POST"
+		}
+		if req.Header.Get("Content-Type") == "" {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+
+	if req.Method == "" {
+		req.Method = "GET"
 	}
 
+	// --compressed asks for every encoding decodeBody supports, unless
+	// Accept-Encoding is given explicitly
+	if req.Compressed && req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+
+	return req, nil
+}
+
+// newHTTPRequest builds the HTTP request for a parsed curl request. The body
+// is created anew on every call, so the request can be sent more than once.
+func newHTTPRequest(ctx context.Context, creq *curlRequest) (*http.Request, error) {
 	var reqBody io.Reader
-	if body != "" {
-		reqBody = strings.NewReader(body)
+	var formContentType string
+	switch {
+	case len(creq.Form) > 0:
+		reqBody, formContentType = multipartBody(creq.Form)
+	case creq.HasBody:
+		reqBody = strings.NewReader(creq.Body)
 	}
 
-	req, err := http.NewRequest(method, url, reqBody)
+	req, err := http.NewRequestWithContext(ctx, creq.Method, creq.URL, reqBody)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		if closer, ok := reqBody.(io.Closer); ok {
+			closer.Close()
+		}
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header = creq.Header.Clone()
+
+	// Like curl, a Content-Type given with -H keeps its value but still
+	// needs the boundary of the generated body
+	if formContentType != "" {
+		if ct := req.Header.Get("Content-Type"); ct == "" {
+			req.Header.Set("Content-Type", formContentType)
+		} else if !strings.Contains(ct, "boundary=") {
+			_, params, _ := mime.ParseMediaType(formContentType)
+			req.Header.Set("Content-Type", ct+"; boundary="+params["boundary"])
+		}
+	}
+
+	applyAuth(req, creq)
+	addCookies(req, creq)
+	return req, nil
+}
+
+// executeRequest executes a parsed curl request, following redirects when
+// -L/--location is given
+func (cb *CurlBatch) executeRequest(creq *curlRequest) (*httpResponse, error) {
+	client, err := cb.client(creq)
+	if err != nil {
+		return nil, err
+	}
+
+	// The maximum time covers the whole operation, including redirects,
+	// the digest challenge and reading the response body
+	connectTimeout, maxTime := cb.timeouts(creq)
+	ctx := httptrace.WithClientTrace(context.Background(), cb.connectionTrace())
+	if maxTime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, maxTime)
+		defer cancel()
 	}
 
-	for _, header := range headers {
-		parts := strings.SplitN(header, ":", 2)
-		if len(parts) == 2 {
-			req.Header.Set(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+	start := time.Now()
+	timer := &requestTimer{}
+	current := creq
+	var redirects []redirectHop
+	for {
+		resp, err := cb.send(ctx, client, current, timer)
+		if err != nil {
+			return nil, err
+		}
+
+		location := resp.Header.Get("Location")
+		if !creq.FollowRedirects || !isRedirect(resp.StatusCode) || location == "" {
+			respBody, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return nil, requestError(ctx, err, "failed to read response", connectTimeout, maxTime)
+			}
+			timer.end(false)
+			respBody, err = decodeBody(resp.Header, respBody)
+			if err != nil {
+				return nil, err
+			}
+
+			return &httpResponse{
+				Status:      resp.Status,
+				StatusCode:  resp.StatusCode,
+				Header:      resp.Header,
+				Body:        respBody,
+				Duration:    time.Since(start),
+				URL:         current.URL,
+				Redirects:   redirects,
+				HTTPVersion: responseHTTPVersion(resp),
+				Timing:      timer.result(),
+			}, nil
 		}
+
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		timer.end(true)
+
+		if limit := creq.maxRedirs(); limit >= 0 && len(redirects) >= limit {
+			return nil, fmt.Errorf("maximum (%d) redirects followed", limit)
+		}
+
+		next, err := redirectRequest(current, resp.StatusCode, location)
+		if err != nil {
+			return nil, err
+		}
+		redirects = append(redirects, redirectHop{StatusCode: resp.StatusCode, URL: current.URL, Location: next.URL})
+		current = next
 	}
+}
 
-	client := &http.Client{Timeout: 30 * time.Second}
+// send sends a single request, answering a digest challenge with a second
+// request when --digest is given. The phases of each request are recorded
+// by timer; the caller ends the last one once it has read the body.
+func (cb *CurlBatch) send(ctx context.Context, client *http.Client, creq *curlRequest, timer *requestTimer) (*http.Response, error) {
+	connectTimeout, maxTime := cb.timeouts(creq)
+
+	req, err := newHTTPRequest(timer.begin(ctx), creq)
+	if err != nil {
+		return nil, err
+	}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("request failed: %w", err)
+		return nil, requestError(ctx, err, "request failed", connectTimeout, maxTime)
+	}
+	storeCookies(creq, req.URL, resp)
+
+	if resp.StatusCode != http.StatusUnauthorized || creq.AuthScheme != authDigest || !creq.hasCredentials() {
+		return resp, nil
+	}
+	challenge := findDigestChallenge(resp.Header)
+	if challenge == nil {
+		return resp, nil
 	}
-	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	timer.end(false)
 
-	respBody, err := io.ReadAll(resp.Body)
+	req, err = newHTTPRequest(timer.begin(ctx), creq)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return nil, err
 	}
+	authorization, err := challenge.authorization(creq.User, creq.Password, req.Method, req.URL.RequestURI())
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", authorization)
 
-	return fmt.Sprintf("Status: %s\nHeaders: %v\nBody: %s", resp.Status, resp.Header, string(respBody)), nil
+	resp, err = client.Do(req)
+	if err != nil {
+		return nil, requestError(ctx, err, "request failed", connectTimeout, maxTime)
+	}
+	storeCookies(creq, req.URL, resp)
+	return resp, nil
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"net/http"
 	"reflect"
 	"testing"
 )
@@ -57,7 +58,7 @@ func TestSplitCurlCommand(t *testing.T) {
 		{
 			name:     "Escaped quotes in data",
 			command:  `curl -d '{"message": "He said \"Hello\""}' https://api.example.com`,
-			expected: []string{"curl", "-d", `{"message": "He said "Hello""}`, "https://api.example.com"},
+			expected: []string{"curl", "-d", `{"message": "He said \"Hello\""}`, "https://api.example.com"},
 			hasError: false,
 		},
 		{
@@ -140,7 +141,7 @@ func TestSplitCurlCommandSpecialCharacters(t *testing.T) {
 		{
 			name:     "Newline in quoted string",
 			command:  "curl -d '{\"message\": \"line1\\nline2\"}' https://api.example.com",
-			expected: []string{"curl", "-d", `{"message": "line1nline2"}`, "https://api.example.com"},
+			expected: []string{"curl", "-d", `{"message": "line1\nline2"}`, "https://api.example.com"},
 		},
 	}
 
@@ -187,13 +188,13 @@ func TestSplitCurlCommandEdgeCases(t *testing.T) {
 		{
 			name:     "Nested quotes different types",
 			command:  `curl -d '{"message": "He said \"Hello\""}' https://api.example.com`,
-			expected: []string{"curl", "-d", `{"message": "He said "Hello""}`, "https://api.example.com"},
+			expected: []string{"curl", "-d", `{"message": "He said \"Hello\""}`, "https://api.example.com"},
 			hasError: false,
 		},
 		{
 			name:     "Backslash escape",
 			command:  `curl -d '{"path": "C:\\Users\\test"}' https://api.example.com`,
-			expected: []string{"curl", "-d", `{"path": "C:\Users\test"}`, "https://api.example.com"},
+			expected: []string{"curl", "-d", `{"path": "C:\\Users\\test"}`, "https://api.example.com"},
 			hasError: false,
 		},
 	}
@@ -220,3 +221,244 @@ func TestSplitCurlCommandEdgeCases(t *testing.T) {
 		})
 	}
 }
+
+func TestSplitCurlCommandMultiLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  string
+		expected []string
+		hasError bool
+	}{
+		{
+			name:     "Line continuations",
+			command:  "curl -X POST \\\n  -H 'Content-Type: application/json' \\\n  https://api.example.com",
+			expected: []string{"curl", "-X", "POST", "-H", "Content-Type: application/json", "https://api.example.com"},
+		},
+		{
+			name:     "CRLF line continuations",
+			command:  "curl -X POST \\\r\n\t-d a=1 \\\r\n\thttps://api.example.com\r\n",
+			expected: []string{"curl", "-X", "POST", "-d", "a=1", "https://api.example.com"},
+		},
+		{
+			name:     "Continuation inside a word",
+			command:  "curl https://api.example.com/us\\\ners",
+			expected: []string{"curl", "https://api.example.com/users"},
+		},
+		{
+			name:     "Continuation inside double quotes",
+			command:  "curl -d \"a=1\\\n&b=2\" https://api.example.com",
+			expected: []string{"curl", "-d", "a=1&b=2", "https://api.example.com"},
+		},
+		{
+			name:     "Comment lines",
+			command:  "# Create a user\ncurl -X POST https://api.example.com/users\n# trailing comment\n",
+			expected: []string{"curl", "-X", "POST", "https://api.example.com/users"},
+		},
+		{
+			name:     "Comment after a word",
+			command:  "curl https://api.example.com # fetch the index",
+			expected: []string{"curl", "https://api.example.com"},
+		},
+		{
+			name:     "Hash inside a word",
+			command:  "curl https://api.example.com/#section",
+			expected: []string{"curl", "https://api.example.com/#section"},
+		},
+		{
+			name:     "Adjacent quoted segments",
+			command:  `curl -H "Authorization: Bearer "abc --data 'a'"b"c https://api.example.com`,
+			expected: []string{"curl", "-H", "Authorization: Bearer abc", "--data", "abc", "https://api.example.com"},
+		},
+		{
+			name:     "ANSI-C quoting",
+			command:  `curl --data-binary $'line1\nline2\t\x41\101\u00e9\'' https://api.example.com`,
+			expected: []string{"curl", "--data-binary", "line1\nline2\tAAé'", "https://api.example.com"},
+		},
+		{
+			name:     "Unclosed ANSI-C quote",
+			command:  `curl -d $'abc https://api.example.com`,
+			hasError: true,
+		},
+		{
+			name:     "Missing continuation",
+			command:  "curl -X POST\n  https://api.example.com",
+			hasError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := splitCurlCommand(tt.command)
+
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+				return
+			}
+
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+// TestSplitCurlCommandShellCorpus checks commands as they appear in API docs
+// and browser "Copy as cURL" output. The expected arguments are what bash
+// produces for the same command line.
+func TestSplitCurlCommandShellCorpus(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  string
+		expected []string
+	}{
+		{
+			name:     "Quoted prefix joined with variable",
+			command:  `curl -H "Authorization: Bearer "abc https://api.example.com`,
+			expected: []string{"curl", "-H", "Authorization: Bearer abc", "https://api.example.com"},
+		},
+		{
+			name:     "Single, double and unquoted segments in one word",
+			command:  `curl --data 'a'"b"c\ d https://api.example.com`,
+			expected: []string{"curl", "--data", "abc d", "https://api.example.com"},
+		},
+		{
+			name:     "Escaped single quote idiom",
+			command:  `curl -d 'It'\''s fine' https://api.example.com`,
+			expected: []string{"curl", "-d", "It's fine", "https://api.example.com"},
+		},
+		{
+			name:     "JSON in double quotes",
+			command:  `curl -d "{\"name\": \"test\", \"path\": \"C:\\tmp\"}" https://api.example.com`,
+			expected: []string{"curl", "-d", `{"name": "test", "path": "C:\tmp"}`, "https://api.example.com"},
+		},
+		{
+			name:     "Backslash in double quotes before ordinary characters",
+			command:  "curl -d \"price: \\$5, cmd: \\`ls\\`, keep: \\n \\x\" https://api.example.com",
+			expected: []string{"curl", "-d", "price: $5, cmd: `ls`, keep: \\n \\x", "https://api.example.com"},
+		},
+		{
+			name:     "Backslash in single quotes is literal",
+			command:  `curl -d 'single \n \" \\ stays' https://api.example.com`,
+			expected: []string{"curl", "-d", `single \n \" \\ stays`, "https://api.example.com"},
+		},
+		{
+			name: "Browser copy as cURL",
+			command: `curl 'https://www.example.com/api/items?id=1' -H 'accept: application/json, text/plain, */*' ` +
+				`-H 'cookie: sid=abc; theme=dark' --data-raw $'{"note":"it\'s \\"quoted\\"\\nnext"}' --compressed`,
+			expected: []string{"curl", "https://www.example.com/api/items?id=1", "-H", "accept: application/json, text/plain, */*",
+				"-H", "cookie: sid=abc; theme=dark", "--data-raw", `{"note":"it's \"quoted\"\nnext"}`, "--compressed"},
+		},
+		{
+			name:     "API documentation example",
+			command:  `curl https://api.stripe.com/v1/charges -u sk_test_123: -d amount=2000 -d "description=Charge for test@example.com"`,
+			expected: []string{"curl", "https://api.stripe.com/v1/charges", "-u", "sk_test_123:", "-d", "amount=2000", "-d", "description=Charge for test@example.com"},
+		},
+		{
+			name:     "Escaped space and ampersand",
+			command:  `curl -H Accept:\ application/json https://api.example.com/a\&b`,
+			expected: []string{"curl", "-H", "Accept: application/json", "https://api.example.com/a&b"},
+		},
+		{
+			name:     "Empty arguments",
+			command:  `curl -d "" -H '' https://api.example.com`,
+			expected: []string{"curl", "-d", "", "-H", "", "https://api.example.com"},
+		},
+		{
+			name:     "Newline inside double quotes",
+			command:  "curl -d \"multi\nline\" https://api.example.com",
+			expected: []string{"curl", "-d", "multi\nline", "https://api.example.com"},
+		},
+		{
+			name:     "Continuation is literal in single quotes only",
+			command:  "curl -d 'a\\\nb' -d \"c\\\nd\" https://api.example.com",
+			expected: []string{"curl", "-d", "a\\\nb", "-d", "cd", "https://api.example.com"},
+		},
+		{
+			name:     "Variables are not expanded",
+			command:  `curl -d $NAME -d "$HOME" https://api.example.com`,
+			expected: []string{"curl", "-d", "$NAME", "-d", "$HOME", "https://api.example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := splitCurlCommand(tt.command)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestParseCurlCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  string
+		expected *curlRequest
+		hasError bool
+	}{
+		{
+			name:    "Simple GET",
+			command: `curl https://api.example.com`,
+			expected: &curlRequest{
+				Method: "GET",
+				URL:    "https://api.example.com",
+				Header: http.Header{},
+			},
+		},
+		{
+			name:    "POST with headers and data",
+			command: `curl -X POST -H "Content-Type: application/json" -H "X-Id: 1" -d '{"name": "test"}' https://api.example.com`,
+			expected: &curlRequest{
+				Method:  "POST",
+				URL:     "https://api.example.com",
+				Header:  http.Header{"Content-Type": {"application/json"}, "X-Id": {"1"}},
+				Body:    `{"name": "test"}`,
+				HasBody: true,
+			},
+		},
+		{
+			name:     "Not a curl command",
+			command:  `wget https://api.example.com`,
+			hasError: true,
+		},
+		{
+			name:     "Unclosed quote",
+			command:  `curl -H "Content-Type https://api.example.com`,
+			hasError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parseCurlCommand(tt.command)
+
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+				return
+			}
+
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, result)
+			}
+		})
+	}
+}
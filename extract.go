@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Extraction pulls a single value out of a JSON response body.
+// It is declared on the command line as name=path, e.g. id=$.data.id.
+type Extraction struct {
+	Name string
+	Path string
+	keys []pathKey
+}
+
+// pathKey is one step of a JSON path: an object key or an array index
+type pathKey struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// parseExtraction parses an extraction of the form name=path
+func parseExtraction(spec string) (*Extraction, error) {
+	name, path, found := strings.Cut(spec, "=")
+	name = strings.TrimSpace(name)
+	path = strings.TrimSpace(path)
+	if !found || name == "" || path == "" {
+		return nil, fmt.Errorf("invalid extraction %q: expected name=path", spec)
+	}
+
+	keys, err := parseJSONPath(path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid extraction %q: %w", spec, err)
+	}
+
+	return &Extraction{Name: name, Path: path, keys: keys}, nil
+}
+
+// parseJSONPath parses a JSONPath-like expression such as $.data.items[0].id,
+// $['key with spaces'] or the gjson-style data.items.0.id
+func parseJSONPath(path string) ([]pathKey, error) {
+	rest := strings.TrimPrefix(path, "$")
+	var keys []pathKey
+
+	for len(rest) > 0 {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("empty key in path %q", path)
+			}
+			keys = append(keys, segmentKey(rest[:end]))
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed bracket in path %q", path)
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				keys = append(keys, pathKey{key: inner[1 : len(inner)-1]})
+				continue
+			}
+			index, err := strconv.Atoi(inner)
+			if err != nil {
+				return nil, fmt.Errorf("invalid index %q in path %q", inner, path)
+			}
+			keys = append(keys, pathKey{index: index, isIndex: true})
+		default:
+			// A path without the leading "$." starts directly with a key
+			if len(keys) > 0 || len(rest) != len(path) {
+				return nil, fmt.Errorf("unexpected %q in path %q", rest[0], path)
+			}
+			rest = "." + rest
+		}
+	}
+
+	return keys, nil
+}
+
+// segmentKey returns the key for a dotted path segment. Numeric segments
+// are array indices, as in gjson paths like items.0.id.
+func segmentKey(segment string) pathKey {
+	if index, err := strconv.Atoi(segment); err == nil {
+		return pathKey{key: segment, index: index, isIndex: true}
+	}
+	return pathKey{key: segment}
+}
+
+// Extract evaluates the extraction against a JSON body. Strings are returned
+// as is, other values as compact JSON. The second return value is false if
+// the body is not JSON or the path does not exist.
+func (e *Extraction) Extract(body []byte) (string, bool) {
+	value, ok := decodeJSON(body)
+	if !ok {
+		return "", false
+	}
+	return e.evaluate(value)
+}
+
+// decodeJSON decodes a JSON body, keeping numbers in their original form
+func decodeJSON(body []byte) (any, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+// evaluate applies the extraction's path to a decoded JSON value
+func (e *Extraction) evaluate(value any) (string, bool) {
+	for _, k := range e.keys {
+		switch v := value.(type) {
+		case map[string]any:
+			next, ok := v[k.key]
+			if !ok {
+				return "", false
+			}
+			value = next
+		case []any:
+			if !k.isIndex {
+				return "", false
+			}
+			index := k.index
+			if index < 0 {
+				index += len(v)
+			}
+			if index < 0 || index >= len(v) {
+				return "", false
+			}
+			value = v[index]
+		default:
+			return "", false
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case nil:
+		return "", true
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return "", false
+		}
+		return string(encoded), true
+	}
+}
+
+// findExtraction returns the extraction with the given name, or nil
+func findExtraction(extractions []*Extraction, name string) *Extraction {
+	for _, e := range extractions {
+		if e.Name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// extractValues evaluates every extraction against the response body.
+// Extractions that do not match are left out of the result.
+func extractValues(extractions []*Extraction, resp *httpResponse) map[string]string {
+	if len(extractions) == 0 || resp == nil {
+		return nil
+	}
+
+	document, ok := decodeJSON(resp.Body)
+	if !ok {
+		return nil
+	}
+
+	values := make(map[string]string)
+	for _, e := range extractions {
+		if value, ok := e.evaluate(document); ok {
+			values[e.Name] = value
+		}
+	}
+	return values
+}
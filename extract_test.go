@@ -0,0 +1,134 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseExtraction(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     string
+		expected *Extraction
+		hasError bool
+	}{
+		{
+			name:     "JSONPath",
+			spec:     "id=$.data.id",
+			expected: &Extraction{Name: "id", Path: "$.data.id", keys: []pathKey{{key: "data"}, {key: "id"}}},
+		},
+		{
+			name:     "Array index",
+			spec:     "first=$.items[0].name",
+			expected: &Extraction{Name: "first", Path: "$.items[0].name", keys: []pathKey{{key: "items"}, {index: 0, isIndex: true}, {key: "name"}}},
+		},
+		{
+			name:     "Bracket key",
+			spec:     "v=$['key with spaces']",
+			expected: &Extraction{Name: "v", Path: "$['key with spaces']", keys: []pathKey{{key: "key with spaces"}}},
+		},
+		{
+			name:     "gjson style",
+			spec:     "id=data.items.1.id",
+			expected: &Extraction{Name: "id", Path: "data.items.1.id", keys: []pathKey{{key: "data"}, {key: "items"}, {key: "1", index: 1, isIndex: true}, {key: "id"}}},
+		},
+		{
+			name:     "Whole document",
+			spec:     "all=$",
+			expected: &Extraction{Name: "all", Path: "$"},
+		},
+		{name: "Missing path", spec: "id=", hasError: true},
+		{name: "Missing name", spec: "=$.id", hasError: true},
+		{name: "No equals sign", spec: "$.id", hasError: true},
+		{name: "Unclosed bracket", spec: "id=$.items[0", hasError: true},
+		{name: "Invalid index", spec: "id=$.items[x]", hasError: true},
+		{name: "Empty key", spec: "id=$..id", hasError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parseExtraction(tt.spec)
+
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+				return
+			}
+
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestExtractionExtract(t *testing.T) {
+	body := []byte(`{
+		"data": {"id": 12345678901234567890, "name": "田中太郎", "active": true, "note": null},
+		"items": [{"id": "a"}, {"id": "b"}],
+		"key with spaces": "yes"
+	}`)
+
+	tests := []struct {
+		path     string
+		expected string
+		ok       bool
+	}{
+		{path: "$.data.id", expected: "12345678901234567890", ok: true},
+		{path: "$.data.name", expected: "田中太郎", ok: true},
+		{path: "$.data.active", expected: "true", ok: true},
+		{path: "$.data.note", expected: "", ok: true},
+		{path: "$.items[1].id", expected: "b", ok: true},
+		{path: "$.items[-1].id", expected: "b", ok: true},
+		{path: "items.0.id", expected: "a", ok: true},
+		{path: "$['key with spaces']", expected: "yes", ok: true},
+		{path: "$.items[0]", expected: `{"id":"a"}`, ok: true},
+		{path: "$.data.missing", ok: false},
+		{path: "$.items[5].id", ok: false},
+		{path: "$.items.id", ok: false},
+		{path: "$.data.name.first", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			e, err := parseExtraction("value=" + tt.path)
+			if err != nil {
+				t.Fatalf("parseExtraction failed: %v", err)
+			}
+
+			result, ok := e.Extract(body)
+			if ok != tt.ok {
+				t.Fatalf("Expected ok=%v, got %v", tt.ok, ok)
+			}
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestExtractValues(t *testing.T) {
+	id, _ := parseExtraction("id=$.data.id")
+	missing, _ := parseExtraction("missing=$.nope")
+	extractions := []*Extraction{id, missing}
+
+	values := extractValues(extractions, &httpResponse{Body: []byte(`{"data": {"id": 7}}`)})
+	expected := map[string]string{"id": "7"}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+
+	if values := extractValues(extractions, &httpResponse{Body: []byte("not json")}); len(values) != 0 {
+		t.Errorf("Expected no values for a non-JSON body, got %v", values)
+	}
+
+	if values := extractValues(extractions, nil); values != nil {
+		t.Errorf("Expected no values without a response, got %v", values)
+	}
+}
@@ -1,3 +1,8 @@
 module curl-batch
 
 go 1.24.4
+
+require (
+	github.com/andybalholm/brotli v1.1.1
+	github.com/klauspost/compress v1.18.0
+)
@@ -1,17 +1,62 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
+	"net/url"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Process exit codes
+const (
+	exitOK          = 0   // no more rows failed than -max-failures allows
+	exitFailures    = 1   // more rows failed than -max-failures allows
+	exitFatal       = 2   // invalid arguments or setup error, no rows were run
+	exitInterrupted = 130 // stopped by SIGINT or SIGTERM
 )
 
 func main() {
 	var curlFile = flag.String("curl", "", "Curl template file (required)")
 	var csvFile = flag.String("csv", "", "CSV data file (required)")
 	var outputFile = flag.String("output", "", "Output file (required)")
-	var sleepMsec = flag.Int("sleep", 0, "Sleep duration in milliseconds between requests")
+	var format = flag.String("format", formatText, "Output format: text, jsonl or csv")
+	var columns = flag.String("columns", strings.Join(defaultColumns, ","), "Result columns for -format csv: status_code, status, duration_ms, error, body, attempts, method, url, redirects, effective_url, http_version, passed, assertion_failures, timeout, time_namelookup, time_connect, time_appconnect, time_pretransfer, time_starttransfer, time_redirect, time_total or an -extract name")
+	var extractSpecs stringList
+	flag.Var(&extractSpecs, "extract", "Extract a value from JSON responses as name=path, e.g. id=$.data.id (repeatable)")
+	var expectStatus = flag.String("expect-status", "", "Expected status codes, classes or ranges, e.g. 200,201 or 2xx or 200-299")
+	var expectHeaders, expectBodyContains, expectBodyRegex, expectJSON stringList
+	flag.Var(&expectHeaders, "expect-header", "Expected response header as Name (present) or Name=value (repeatable)")
+	flag.Var(&expectBodyContains, "expect-body-contains", "Expected substring of the response body (repeatable)")
+	flag.Var(&expectBodyRegex, "expect-body-regex", "Regular expression the response body must match (repeatable)")
+	flag.Var(&expectJSON, "expect-json", "Expected JSON value as path=value, e.g. $.status=active (repeatable)")
+	var maxFailures = flag.Int("max-failures", 0, "Number of failed rows tolerated before exiting with status 1")
+	var checkpointFile = flag.String("checkpoint", "", "Checkpoint file recording completed rows")
+	var resume = flag.Bool("resume", false, "Skip rows recorded as completed in the -checkpoint file")
+	var sleepMsec = flag.Int("sleep", 0, "Sleep duration in milliseconds between requests (per worker)")
+	var concurrency = flag.Int("concurrency", 1, "Number of requests to execute in parallel")
+	var rate = flag.String("rate", "", "Maximum request rate, e.g. 20/s or 100/m")
+	var burst = flag.Int("burst", 1, "Number of requests allowed to start at once when -rate is set")
+	var maxAttempts = flag.Int("max-attempts", 1, "Maximum number of attempts per request (1 disables retries)")
+	var retryBackoff = flag.Duration("retry-backoff", time.Second, "Backoff before the first retry, doubled on every further retry")
+	var retryMaxBackoff = flag.Duration("retry-max-backoff", 30*time.Second, "Maximum backoff between retries")
+	var retryJitter = flag.Float64("retry-jitter", 0.2, "Fraction of the backoff that is randomised (0-1)")
+	var retryStatus = flag.String("retry-status", "429,502,503,504", "Comma separated HTTP status codes that are retried")
+	var retryNetErrors = flag.Bool("retry-network-errors", true, "Retry requests that fail with a network error")
+	var connectTimeout = flag.Duration("connect-timeout", 0, "Default connect timeout for requests without --connect-timeout (0 uses the transport default of 30s)")
+	var maxTime = flag.Duration("max-time", defaultMaxTime, "Default maximum time for requests without -m/--max-time (0 disables the limit)")
+	var proxy = flag.String("proxy", "", "Proxy for every request (http, https, socks5 or socks5h URL), overriding -x in the template")
+	var noProxy = flag.String("noproxy", "", "Comma separated hosts that bypass the proxy, overriding --noproxy in the template")
+	var maxIdleConns = flag.Int("max-idle-conns-per-host", 0, "Idle connections kept per host for reuse by later rows (0 keeps one per worker)")
+	var idleTimeout = flag.Duration("idle-timeout", defaultIdleTimeout, "How long an idle connection is kept for reuse (0 keeps it until the batch ends)")
+	var useHTTP2 = flag.Bool("http2", true, "Use HTTP/2 with servers that support it, unless the template has an --http* option")
+	var keepAlive = flag.Bool("keep-alive", true, "Reuse connections between requests")
+	var cookieSession = flag.String("cookie-session", cookieSessionRow, "Cookie jar scope: row (a fresh jar per CSV row) or shared (one jar for the whole batch)")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s -curl <file> -csv <file> -output <file> [options]\n", os.Args[0])
@@ -22,25 +67,242 @@ func main() {
 	flag.Parse()
 
 	if *curlFile == "" || *csvFile == "" || *outputFile == "" {
-		fmt.Fprintf(os.Stderr, "Error: All required flags must be specified\n\n")
-		flag.Usage()
-		os.Exit(1)
+		usageError("All required flags must be specified")
+	}
+
+	if *resume && *checkpointFile == "" {
+		usageError("-resume requires -checkpoint")
+	}
+
+	if err := checkOutputFormat(*format); err != nil {
+		usageError("-format: %v", err)
+	}
+
+	var extractions []*Extraction
+	for _, spec := range extractSpecs {
+		extraction, err := parseExtraction(spec)
+		if err != nil {
+			usageError("-extract: %v", err)
+		}
+		if _, ok := resultColumns[extraction.Name]; ok || findExtraction(extractions, extraction.Name) != nil {
+			usageError("-extract: duplicate column name %q", extraction.Name)
+		}
+		extractions = append(extractions, extraction)
+	}
+
+	assertions, err := parseAssertions(*expectStatus, expectHeaders, expectBodyContains, expectBodyRegex, expectJSON)
+	if err != nil {
+		usageError("%v", err)
+	}
+
+	selectedColumns, err := parseColumns(*columns, extractions)
+	if err != nil {
+		usageError("-columns: %v", err)
+	}
+
+	if *concurrency < 1 {
+		usageError("-concurrency must be at least 1")
+	}
+
+	if *maxFailures < 0 {
+		usageError("-max-failures must not be negative")
+	}
+
+	var limiter *RateLimiter
+	if *rate != "" {
+		perSecond, err := parseRate(*rate)
+		if err != nil {
+			usageError("%v", err)
+		}
+		limiter = NewRateLimiter(perSecond, *burst)
+	}
+
+	retryStatusCodes, err := parseStatusList(*retryStatus)
+	if err != nil {
+		usageError("-retry-status: %v", err)
+	}
+	if *retryJitter < 0 || *retryJitter > 1 {
+		usageError("-retry-jitter must be between 0 and 1")
+	}
+
+	if *connectTimeout < 0 || *maxTime < 0 {
+		usageError("-connect-timeout and -max-time must not be negative")
+	}
+
+	var proxyURL *url.URL
+	if *proxy != "" {
+		proxyURL, err = parseProxyURL(*proxy)
+		if err != nil {
+			usageError("-proxy: %v", err)
+		}
+	}
+
+	if *maxIdleConns < 0 || *idleTimeout < 0 {
+		usageError("-max-idle-conns-per-host and -idle-timeout must not be negative")
+	}
+
+	if err := checkCookieSession(*cookieSession); err != nil {
+		usageError("-cookie-session: %v", err)
 	}
 
 	batch, err := NewCurlBatch(*curlFile, *csvFile, *outputFile, *sleepMsec)
 	if err != nil {
-		log.Fatalf("Failed to initialize curl batch: %v", err)
+		fatalf("Failed to initialize curl batch: %v", err)
+	}
+	batch.Format = *format
+	batch.Columns = selectedColumns
+	batch.Extractions = extractions
+	batch.Assertions = assertions
+	batch.Concurrency = *concurrency
+	batch.RateLimiter = limiter
+	batch.ConnectTimeout = *connectTimeout
+	batch.MaxTime = *maxTime
+	batch.Proxy = proxyURL
+	batch.NoProxy = *noProxy
+	batch.CookieSession = *cookieSession
+	batch.Pool = PoolOptions{
+		MaxIdleConnsPerHost: *maxIdleConns,
+		IdleTimeout:         *idleTimeout,
+		DisableHTTP2:        !*useHTTP2,
+		DisableKeepAlives:   !*keepAlive,
+	}
+	batch.Retry = &RetryPolicy{
+		MaxAttempts:    *maxAttempts,
+		BaseBackoff:    *retryBackoff,
+		MaxBackoff:     *retryMaxBackoff,
+		Jitter:         *retryJitter,
+		RetryStatus:    retryStatusCodes,
+		RetryNetErrors: *retryNetErrors,
 	}
 
-	fmt.Printf("Starting batch execution with %d requests", len(batch.CSVData))
+	if *checkpointFile != "" {
+		checkpoint, err := OpenCheckpoint(*checkpointFile, *resume)
+		if err != nil {
+			fatalf("Failed to open checkpoint file: %v", err)
+		}
+		batch.Checkpoint = checkpoint
+	}
+
+	remaining := len(batch.pendingRows())
+	fmt.Printf("Starting batch execution with %d requests", remaining)
+	if skipped := len(batch.CSVData) - remaining; skipped > 0 {
+		fmt.Printf(" (resuming: %d already completed)", skipped)
+	}
+	if *concurrency > 1 {
+		fmt.Printf(" (concurrency: %d)", *concurrency)
+	}
+	if *rate != "" {
+		fmt.Printf(" (rate: %s, burst: %d)", *rate, *burst)
+	}
+	if *maxAttempts > 1 {
+		fmt.Printf(" (max attempts: %d)", *maxAttempts)
+	}
 	if *sleepMsec > 0 {
 		fmt.Printf(" (sleep: %dms between requests)", *sleepMsec)
 	}
 	fmt.Println("...")
 
-	if err := batch.Run(); err != nil {
-		log.Fatalf("Failed to run batch: %v", err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	handleSignals(cancel)
+
+	summary, err := batch.Run(ctx)
+	if err != nil {
+		fatalf("Failed to run batch: %v", err)
 	}
 
+	summary.Print(os.Stdout)
+
+	if summary.Interrupted {
+		fmt.Printf("Batch execution interrupted. Partial results saved to %s\n", *outputFile)
+		os.Exit(exitInterrupted)
+	}
 	fmt.Printf("Batch execution completed. Results saved to %s\n", *outputFile)
+
+	if summary.Failed > *maxFailures {
+		fmt.Fprintf(os.Stderr, "%d rows failed (allowed: %d)\n", summary.Failed, *maxFailures)
+		os.Exit(exitFailures)
+	}
+}
+
+// usageError reports an invalid command line and exits
+func usageError(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "Error: "+format+"\n\n", args...)
+	flag.Usage()
+	os.Exit(exitFatal)
+}
+
+// fatalf logs a setup error that prevents the batch from running and exits
+func fatalf(format string, args ...any) {
+	log.Printf(format, args...)
+	os.Exit(exitFatal)
+}
+
+// parseAssertions builds the response assertions from the -expect-* flags
+func parseAssertions(status string, headers, bodyContains, bodyRegex, jsonValues []string) ([]*Assertion, error) {
+	var assertions []*Assertion
+
+	if status != "" {
+		a, err := parseStatusAssertion(status)
+		if err != nil {
+			return nil, fmt.Errorf("-expect-status: %w", err)
+		}
+		assertions = append(assertions, a)
+	}
+	for _, spec := range headers {
+		a, err := parseHeaderAssertion(spec)
+		if err != nil {
+			return nil, fmt.Errorf("-expect-header: %w", err)
+		}
+		assertions = append(assertions, a)
+	}
+	for _, substr := range bodyContains {
+		assertions = append(assertions, bodyContainsAssertion(substr))
+	}
+	for _, expr := range bodyRegex {
+		a, err := parseBodyRegexAssertion(expr)
+		if err != nil {
+			return nil, fmt.Errorf("-expect-body-regex: %w", err)
+		}
+		assertions = append(assertions, a)
+	}
+	for _, spec := range jsonValues {
+		a, err := parseJSONAssertion(spec)
+		if err != nil {
+			return nil, fmt.Errorf("-expect-json: %w", err)
+		}
+		assertions = append(assertions, a)
+	}
+
+	return assertions, nil
+}
+
+// handleSignals calls cancel on the first SIGINT or SIGTERM so that no new
+// requests are started while in-flight requests finish. A second signal
+// exits immediately.
+func handleSignals(cancel context.CancelFunc) {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-signals
+		fmt.Fprintf(os.Stderr, "\nInterrupted: waiting for in-flight requests to finish (press Ctrl-C again to force exit)\n")
+		cancel()
+
+		<-signals
+		fmt.Fprintf(os.Stderr, "Forced exit\n")
+		os.Exit(exitInterrupted)
+	}()
+}
+
+// stringList is a flag.Value collecting every occurrence of a repeatable flag
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ", ")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
 }
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Output formats supported by -format
+const (
+	formatText  = "text"
+	formatJSONL = "jsonl"
+	formatCSV   = "csv"
+)
+
+// resultWriter writes the result of each row to the output file
+type resultWriter interface {
+	WriteResult(r *rowResult) error
+}
+
+// checkOutputFormat returns an error if format is not a supported output format
+func checkOutputFormat(format string) error {
+	switch format {
+	case "", formatText, formatJSONL, formatCSV:
+		return nil
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+// newResultWriter returns the resultWriter for the batch's output format.
+// An empty format selects the text format.
+func (cb *CurlBatch) newResultWriter() (resultWriter, error) {
+	switch cb.Format {
+	case "", formatText:
+		return &textWriter{w: cb.OutputFile}, nil
+	case formatJSONL:
+		return &jsonlWriter{w: cb.OutputFile}, nil
+	case formatCSV:
+		// Only write the header row when starting a new file, not when
+		// appending to the output of a previous run
+		info, err := cb.OutputFile.Stat()
+		if err != nil {
+			return nil, err
+		}
+		return newCSVWriter(cb.OutputFile, cb.CSVHeaders, cb.csvColumns(), info.Size() == 0)
+	default:
+		return nil, checkOutputFormat(cb.Format)
+	}
+}
+
+// textWriter writes human readable "=== Request N ===" blocks
+type textWriter struct {
+	w io.Writer
+}
+
+// WriteResult writes a single request block.
+// The block is written with a single call so it is never interleaved.
+func (tw *textWriter) WriteResult(r *rowResult) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "=== Request %d ===\n", r.Index+1)
+	fmt.Fprintf(&b, "Command: %s\n", r.Command)
+	fmt.Fprintf(&b, "Data: %+v\n", r.Row)
+
+	if len(r.Attempts) > 1 {
+		for n, attempt := range r.Attempts {
+			fmt.Fprintf(&b, "Attempt %d: ", n+1)
+			if attempt.Err != nil {
+				fmt.Fprintf(&b, "Error: %s", attempt.Err)
+			} else {
+				fmt.Fprintf(&b, "Status: %s", attempt.Response.Status)
+			}
+			if attempt.Wait > 0 {
+				fmt.Fprintf(&b, " (retrying in %s)", attempt.Wait.Round(time.Millisecond))
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	if r.Response != nil {
+		for _, hop := range r.Response.Redirects {
+			fmt.Fprintf(&b, "Redirect: %d %s -> %s\n", hop.StatusCode, hop.URL, hop.Location)
+		}
+	}
+
+	if r.timeout() != "" {
+		fmt.Fprintf(&b, "Timeout: %s\n", r.Err)
+	} else if r.Err != nil {
+		fmt.Fprintf(&b, "Error: %s\n", r.Err)
+	} else {
+		fmt.Fprintf(&b, "Result:\n%s\n", r.Response)
+		if r.Response.Timing.Total > 0 {
+			fmt.Fprintf(&b, "Timing: %s\n", r.Response.Timing)
+		}
+	}
+
+	if len(r.Extracted) > 0 {
+		fmt.Fprintf(&b, "Extracted: %+v\n", r.Extracted)
+	}
+
+	for _, failure := range r.Failures {
+		fmt.Fprintf(&b, "Assertion failed: %s\n", failure)
+	}
+
+	b.WriteString("\n")
+
+	_, err := io.WriteString(tw.w, b.String())
+	return err
+}
+
+// jsonlWriter writes one JSON object per row
+type jsonlWriter struct {
+	w io.Writer
+}
+
+// jsonlRecord is the JSON object written for each row by jsonlWriter
+type jsonlRecord struct {
+	Row             int               `json:"row"`
+	Data            map[string]string `json:"data"`
+	Command         string            `json:"command"`
+	Method          string            `json:"method,omitempty"`
+	URL             string            `json:"url,omitempty"`
+	RequestHeaders  http.Header       `json:"request_headers,omitempty"`
+	RequestBody     string            `json:"request_body,omitempty"`
+	StatusCode      int               `json:"status_code,omitempty"`
+	Status          string            `json:"status,omitempty"`
+	ResponseHeaders http.Header       `json:"response_headers,omitempty"`
+	Body            *string           `json:"body,omitempty"`
+	BodyBase64      []byte            `json:"body_base64,omitempty"`
+	DurationMs      float64           `json:"duration_ms"`
+	Redirects       []redirectHop     `json:"redirects,omitempty"`
+	EffectiveURL    string            `json:"effective_url,omitempty"`
+	HTTPVersion     string            `json:"http_version,omitempty"`
+	Timing          *jsonlTiming      `json:"timing,omitempty"`
+	Attempts        int               `json:"attempts"`
+	AttemptsDetail  []jsonlAttempt    `json:"attempts_detail,omitempty"`
+	Extracted       map[string]string `json:"extracted,omitempty"`
+	Passed          bool              `json:"passed"`
+	Failures        []string          `json:"assertion_failures,omitempty"`
+	Error           string            `json:"error,omitempty"`
+	Timeout         string            `json:"timeout,omitempty"`
+}
+
+// jsonlAttempt is a single attempt of the request in jsonlRecord
+type jsonlAttempt struct {
+	StatusCode int     `json:"status_code,omitempty"`
+	Error      string  `json:"error,omitempty"`
+	WaitMs     float64 `json:"wait_ms"` // backoff before the next attempt, 0 for the last one
+}
+
+// WriteResult writes a single JSON line
+func (jw *jsonlWriter) WriteResult(r *rowResult) error {
+	record := jsonlRecord{
+		Row:       r.Index + 1,
+		Data:      r.Row,
+		Command:   r.Command,
+		Attempts:  len(r.Attempts),
+		Extracted: r.Extracted,
+		Passed:    !r.failed(),
+		Failures:  r.Failures,
+	}
+
+	for _, attempt := range r.Attempts {
+		detail := jsonlAttempt{WaitMs: durationMs(attempt.Wait)}
+		if attempt.Err != nil {
+			detail.Error = attempt.Err.Error()
+		} else if attempt.Response != nil {
+			detail.StatusCode = attempt.Response.StatusCode
+		}
+		record.AttemptsDetail = append(record.AttemptsDetail, detail)
+	}
+
+	if r.Request != nil {
+		record.Method = r.Request.Method
+		record.URL = r.Request.URL
+		record.RequestHeaders = r.Request.Header
+		record.RequestBody = r.Request.Body
+	}
+
+	if r.Response != nil {
+		record.StatusCode = r.Response.StatusCode
+		record.Status = r.Response.Status
+		record.ResponseHeaders = r.Response.Header
+		record.DurationMs = durationMs(r.Response.Duration)
+		record.Redirects = r.Response.Redirects
+		record.HTTPVersion = r.Response.HTTPVersion
+		record.Timing = newJSONLTiming(r.Response.Timing)
+		if len(r.Response.Redirects) > 0 {
+			record.EffectiveURL = r.Response.URL
+		}
+		// Binary bodies cannot be represented as a JSON string
+		if utf8.Valid(r.Response.Body) {
+			body := string(r.Response.Body)
+			record.Body = &body
+		} else {
+			record.BodyBase64 = r.Response.Body
+		}
+	}
+
+	if r.Err != nil {
+		record.Error = r.Err.Error()
+		record.Timeout = r.timeout()
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	_, err = jw.w.Write(append(line, '\n'))
+	return err
+}
+
+// durationMs converts d to fractional milliseconds
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// Result columns that can be selected with -columns for the csv format
+var resultColumns = map[string]func(r *rowResult) string{
+	"status_code": func(r *rowResult) string {
+		if r.Response == nil {
+			return ""
+		}
+		return strconv.Itoa(r.Response.StatusCode)
+	},
+	"status": func(r *rowResult) string {
+		if r.Response == nil {
+			return ""
+		}
+		return r.Response.Status
+	},
+	"duration_ms": func(r *rowResult) string {
+		if r.Response == nil {
+			return ""
+		}
+		return strconv.FormatFloat(durationMs(r.Response.Duration), 'f', -1, 64)
+	},
+	"error": func(r *rowResult) string {
+		if r.Err == nil {
+			return ""
+		}
+		return r.Err.Error()
+	},
+	"timeout": func(r *rowResult) string {
+		return r.timeout()
+	},
+	"body": func(r *rowResult) string {
+		if r.Response == nil {
+			return ""
+		}
+		return string(r.Response.Body)
+	},
+	"attempts": func(r *rowResult) string {
+		return strconv.Itoa(len(r.Attempts))
+	},
+	"method": func(r *rowResult) string {
+		if r.Request == nil {
+			return ""
+		}
+		return r.Request.Method
+	},
+	"url": func(r *rowResult) string {
+		if r.Request == nil {
+			return ""
+		}
+		return r.Request.URL
+	},
+	"redirects": func(r *rowResult) string {
+		if r.Response == nil {
+			return ""
+		}
+		return strconv.Itoa(len(r.Response.Redirects))
+	},
+	"effective_url": func(r *rowResult) string {
+		if r.Response == nil {
+			return ""
+		}
+		return r.Response.URL
+	},
+	"http_version": func(r *rowResult) string {
+		if r.Response == nil {
+			return ""
+		}
+		return r.Response.HTTPVersion
+	},
+	"time_namelookup":    timingColumn("time_namelookup"),
+	"time_connect":       timingColumn("time_connect"),
+	"time_appconnect":    timingColumn("time_appconnect"),
+	"time_pretransfer":   timingColumn("time_pretransfer"),
+	"time_starttransfer": timingColumn("time_starttransfer"),
+	"time_redirect":      timingColumn("time_redirect"),
+	"time_total":         timingColumn("time_total"),
+	"passed": func(r *rowResult) string {
+		return strconv.FormatBool(!r.failed())
+	},
+	"assertion_failures": func(r *rowResult) string {
+		return strings.Join(r.Failures, "; ")
+	},
+}
+
+// defaultColumns are the result columns written when -columns is not given
+var defaultColumns = []string{"status_code", "duration_ms", "error"}
+
+// parseColumns parses a comma separated list of result column names.
+// Besides the built-in result columns, the names of extractions are allowed.
+func parseColumns(list string, extractions []*Extraction) ([]string, error) {
+	var columns []string
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := resultColumns[name]; !ok && findExtraction(extractions, name) == nil {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		columns = append(columns, name)
+	}
+	return columns, nil
+}
+
+// csvColumns returns the result columns for the csv format: the selected
+// columns followed by any extraction that was not selected explicitly
+func (cb *CurlBatch) csvColumns() []string {
+	columns := cb.Columns
+	if len(columns) == 0 {
+		columns = defaultColumns
+	}
+	columns = append([]string{}, columns...)
+
+	for _, e := range cb.Extractions {
+		if !slices.Contains(columns, e.Name) {
+			columns = append(columns, e.Name)
+		}
+	}
+	return columns
+}
+
+// csvWriter writes the original CSV columns of each row followed by the
+// selected result columns, so the output lines up with the input file
+type csvWriter struct {
+	w       *csv.Writer
+	headers []string
+	columns []string
+}
+
+// newCSVWriter creates a csvWriter, writing the header row if writeHeader is set
+func newCSVWriter(w io.Writer, headers, columns []string, writeHeader bool) (*csvWriter, error) {
+	if len(columns) == 0 {
+		columns = defaultColumns
+	}
+	cw := &csvWriter{w: csv.NewWriter(w), headers: headers, columns: columns}
+
+	if writeHeader {
+		record := append(append([]string{}, headers...), columns...)
+		if err := cw.w.Write(record); err != nil {
+			return nil, err
+		}
+		cw.w.Flush()
+		if err := cw.w.Error(); err != nil {
+			return nil, err
+		}
+	}
+
+	return cw, nil
+}
+
+// WriteResult writes a single CSV record
+func (cw *csvWriter) WriteResult(r *rowResult) error {
+	record := make([]string, 0, len(cw.headers)+len(cw.columns))
+	for _, header := range cw.headers {
+		record = append(record, r.Row[header])
+	}
+	for _, column := range cw.columns {
+		if value, ok := resultColumns[column]; ok {
+			record = append(record, value(r))
+		} else {
+			record = append(record, r.Extracted[column])
+		}
+	}
+
+	if err := cw.w.Write(record); err != nil {
+		return err
+	}
+	cw.w.Flush()
+	return cw.w.Error()
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckOutputFormat(t *testing.T) {
+	for _, format := range []string{"", "text", "jsonl", "csv"} {
+		if err := checkOutputFormat(format); err != nil {
+			t.Errorf("Unexpected error for format %q: %v", format, err)
+		}
+	}
+
+	if err := checkOutputFormat("xml"); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
+
+func TestTextWriter(t *testing.T) {
+	var b strings.Builder
+	w := &textWriter{w: &b}
+
+	r := &rowResult{
+		Index:   0,
+		Row:     map[string]string{"NAME": "test"},
+		Command: "curl https://api.example.com",
+		Response: &httpResponse{
+			Status:     "200 OK",
+			StatusCode: 200,
+			Header:     http.Header{},
+			Body:       []byte("hello"),
+		},
+		Attempts: []attemptResult{
+			{Err: errors.New("request failed: EOF"), Wait: time.Second},
+			{Response: &httpResponse{Status: "200 OK"}},
+		},
+	}
+
+	if err := w.WriteResult(r); err != nil {
+		t.Fatalf("WriteResult failed: %v", err)
+	}
+
+	expected := `=== Request 1 ===
+Command: curl https://api.example.com
+Data: map[NAME:test]
+Attempt 1: Error: request failed: EOF (retrying in 1s)
+Attempt 2: Status: 200 OK
+Result:
+Status: 200 OK
+Headers: map[]
+Body: hello
+
+`
+	if b.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, b.String())
+	}
+}
+
+func TestJSONLWriter(t *testing.T) {
+	var b strings.Builder
+	w := &jsonlWriter{w: &b}
+
+	results := []*rowResult{
+		{
+			Index:   0,
+			Row:     map[string]string{"NAME": "田中太郎"},
+			Command: `curl -X POST -d '{"name": "田中太郎"}' https://api.example.com`,
+			Request: &curlRequest{
+				Method: "POST",
+				URL:    "https://api.example.com",
+				Header: http.Header{"Content-Type": {"application/json"}},
+				Body:   `{"name": "田中太郎"}`,
+			},
+			Response: &httpResponse{
+				Status:     "201 Created",
+				StatusCode: 201,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       []byte(`{"id": 1}`),
+				Duration:   1500 * time.Microsecond,
+			},
+			Attempts: []attemptResult{{}},
+		},
+		{
+			Index:   1,
+			Row:     map[string]string{"NAME": "佐藤花子"},
+			Command: "curl https://api.example.com",
+			Request: &curlRequest{Method: "GET", URL: "https://api.example.com", Header: http.Header{}},
+			Err:     errors.New("request failed: connection refused"),
+			Attempts: []attemptResult{
+				{Response: &httpResponse{Status: "503 Service Unavailable", StatusCode: 503}, Wait: 500 * time.Millisecond},
+				{Err: errors.New("request failed: connection refused")},
+			},
+		},
+		{
+			Index:   2,
+			Row:     map[string]string{"NAME": "binary"},
+			Command: "curl https://api.example.com",
+			Response: &httpResponse{
+				Status:     "200 OK",
+				StatusCode: 200,
+				Body:       []byte{0xff, 0xfe},
+			},
+			Attempts: []attemptResult{{}},
+		},
+	}
+
+	for _, r := range results {
+		if err := w.WriteResult(r); err != nil {
+			t.Fatalf("WriteResult failed: %v", err)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 lines, got %d: %q", len(lines), b.String())
+	}
+
+	var first map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("Invalid JSON %q: %v", lines[0], err)
+	}
+	checks := map[string]any{
+		"row":          float64(1),
+		"method":       "POST",
+		"url":          "https://api.example.com",
+		"request_body": `{"name": "田中太郎"}`,
+		"status_code":  float64(201),
+		"body":         `{"id": 1}`,
+		"duration_ms":  1.5,
+		"attempts":     float64(1),
+	}
+	for key, want := range checks {
+		if first[key] != want {
+			t.Errorf("Line 1: expected %s=%v, got %v", key, want, first[key])
+		}
+	}
+	if first["data"].(map[string]any)["NAME"] != "田中太郎" {
+		t.Errorf("Line 1: expected CSV data, got %v", first["data"])
+	}
+	if _, ok := first["error"]; ok {
+		t.Errorf("Line 1: expected no error, got %v", first["error"])
+	}
+
+	var second map[string]any
+	if err := json.Unmarshal([]byte(lines[1]), &second); err != nil {
+		t.Fatalf("Invalid JSON %q: %v", lines[1], err)
+	}
+	if second["error"] != "request failed: connection refused" || second["attempts"] != float64(2) {
+		t.Errorf("Line 2: unexpected record %v", second)
+	}
+	if _, ok := second["status_code"]; ok {
+		t.Errorf("Line 2: expected no status code, got %v", second["status_code"])
+	}
+	expectedAttempts := []any{
+		map[string]any{"status_code": float64(503), "wait_ms": float64(500)},
+		map[string]any{"error": "request failed: connection refused", "wait_ms": float64(0)},
+	}
+	if !reflect.DeepEqual(second["attempts_detail"], expectedAttempts) {
+		t.Errorf("Line 2: expected attempts_detail %v, got %v", expectedAttempts, second["attempts_detail"])
+	}
+
+	var third map[string]any
+	if err := json.Unmarshal([]byte(lines[2]), &third); err != nil {
+		t.Fatalf("Invalid JSON %q: %v", lines[2], err)
+	}
+	if third["body_base64"] != "//4=" {
+		t.Errorf("Line 3: expected base64 body, got %v", third)
+	}
+}
+
+func TestParseColumns(t *testing.T) {
+	columns, err := parseColumns("status_code, duration_ms,body", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []string{"status_code", "duration_ms", "body"}
+	if strings.Join(columns, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, columns)
+	}
+
+	if _, err := parseColumns("status_code,unknown", nil); err == nil {
+		t.Error("Expected error for unknown column")
+	}
+
+	extractions := []*Extraction{{Name: "id", Path: "$.id"}}
+	if _, err := parseColumns("id,status_code", extractions); err != nil {
+		t.Errorf("Expected extraction name to be a valid column: %v", err)
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	var b strings.Builder
+	w, err := newCSVWriter(&b, []string{"NAME", "EMAIL"}, []string{"status_code", "duration_ms", "error", "body"}, true)
+	if err != nil {
+		t.Fatalf("newCSVWriter failed: %v", err)
+	}
+
+	results := []*rowResult{
+		{
+			Row: map[string]string{"NAME": "田中太郎", "EMAIL": "tanaka@example.com"},
+			Response: &httpResponse{
+				StatusCode: 201,
+				Body:       []byte(`{"id": 1, "note": "a, b"}`),
+				Duration:   12500 * time.Microsecond,
+			},
+		},
+		{
+			Row: map[string]string{"NAME": "佐藤花子", "EMAIL": "sato@example.com"},
+			Err: errors.New("request failed: connection refused"),
+		},
+	}
+	for _, r := range results {
+		if err := w.WriteResult(r); err != nil {
+			t.Fatalf("WriteResult failed: %v", err)
+		}
+	}
+
+	expected := `NAME,EMAIL,status_code,duration_ms,error,body
+田中太郎,tanaka@example.com,201,12.5,,"{""id"": 1, ""note"": ""a, b""}"
+佐藤花子,sato@example.com,,,request failed: connection refused,
+`
+	if b.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, b.String())
+	}
+}
+
+func TestCSVWriterWithoutHeader(t *testing.T) {
+	var b strings.Builder
+	w, err := newCSVWriter(&b, []string{"NAME"}, nil, false)
+	if err != nil {
+		t.Fatalf("newCSVWriter failed: %v", err)
+	}
+
+	r := &rowResult{
+		Row:      map[string]string{"NAME": "test"},
+		Response: &httpResponse{StatusCode: 200, Duration: time.Millisecond},
+	}
+	if err := w.WriteResult(r); err != nil {
+		t.Fatalf("WriteResult failed: %v", err)
+	}
+
+	// Default columns are used and no header row is written
+	expected := "test,200,1,\n"
+	if b.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, b.String())
+	}
+}
+
+func TestCSVColumnsIncludeExtractions(t *testing.T) {
+	cb := &CurlBatch{
+		Columns:     []string{"id", "status_code"},
+		Extractions: []*Extraction{{Name: "id"}, {Name: "name"}},
+	}
+
+	expected := []string{"id", "status_code", "name"}
+	if columns := cb.csvColumns(); strings.Join(columns, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, got %v", expected, columns)
+	}
+
+	var b strings.Builder
+	w, err := newCSVWriter(&b, []string{"NAME"}, cb.csvColumns(), false)
+	if err != nil {
+		t.Fatalf("newCSVWriter failed: %v", err)
+	}
+	r := &rowResult{
+		Row:       map[string]string{"NAME": "test"},
+		Response:  &httpResponse{StatusCode: 201},
+		Extracted: map[string]string{"id": "42"},
+	}
+	if err := w.WriteResult(r); err != nil {
+		t.Fatalf("WriteResult failed: %v", err)
+	}
+	if b.String() != "test,42,201,\n" {
+		t.Errorf("Expected %q, got %q", "test,42,201,\n", b.String())
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// HTTP versions selected with curl's --http* options
+const (
+	http10              = "1.0"               // -0/--http1.0
+	http11              = "1.1"               // --http1.1
+	http2               = "2"                 // --http2
+	http2PriorKnowledge = "2-prior-knowledge" // --http2-prior-knowledge
+)
+
+// httpVersionOption returns the apply function for one of the --http* options
+func httpVersionOption(version string) func(p *curlParser, value string) error {
+	return func(p *curlParser, value string) error {
+		p.req.HTTPVersion = version
+		return nil
+	}
+}
+
+// setHTTPVersion restricts a transport to the protocols of an HTTP version.
+// It takes precedence over the -http2 option of the batch.
+//
+// Net/http always writes HTTP/1.1 requests, so --http1.0 only gets
+// HTTP/1.0's connection handling: no HTTP/2 and a new connection for every
+// request. --http2 negotiates HTTP/2 over TLS and falls back to HTTP/1.1;
+// like curl without an upgrade, plain http:// URLs use HTTP/1.1.
+// --http2-prior-knowledge speaks HTTP/2 directly, over TLS or as h2c.
+func setHTTPVersion(transport *http.Transport, version string) {
+	protocols := new(http.Protocols)
+	switch version {
+	case http10:
+		protocols.SetHTTP1(true)
+		transport.DisableKeepAlives = true
+		transport.ForceAttemptHTTP2 = false
+	case http11:
+		protocols.SetHTTP1(true)
+		transport.ForceAttemptHTTP2 = false
+	case http2:
+		protocols.SetHTTP1(true)
+		protocols.SetHTTP2(true)
+		transport.ForceAttemptHTTP2 = true
+	case http2PriorKnowledge:
+		protocols.SetHTTP2(true)
+		protocols.SetUnencryptedHTTP2(true)
+		transport.ForceAttemptHTTP2 = true
+	default:
+		return
+	}
+	transport.Protocols = protocols
+}
+
+// responseHTTPVersion returns the protocol of a response the way curl's
+// %{http_version} does: "1.0", "1.1" or "2"
+func responseHTTPVersion(resp *http.Response) string {
+	if resp.ProtoMajor >= 2 {
+		return strconv.Itoa(resp.ProtoMajor)
+	}
+	return strconv.Itoa(resp.ProtoMajor) + "." + strconv.Itoa(resp.ProtoMinor)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseHTTPVersionOptions(t *testing.T) {
+	tests := []struct {
+		command  string
+		expected string
+	}{
+		{`curl https://api.example.com`, ""},
+		{`curl -0 https://api.example.com`, http10},
+		{`curl --http1.0 https://api.example.com`, http10},
+		{`curl --http1.1 https://api.example.com`, http11},
+		{`curl --http2 https://api.example.com`, http2},
+		{`curl --http2-prior-knowledge https://api.example.com`, http2PriorKnowledge},
+		{`curl --http2 --http1.1 https://api.example.com`, http11},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			req := mustParseCurlCommand(t, tt.command)
+			if req.HTTPVersion != tt.expected {
+				t.Errorf("Expected HTTP version %q, got %q", tt.expected, req.HTTPVersion)
+			}
+		})
+	}
+}
+
+func TestHTTPVersions(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})
+
+	tlsServer := httptest.NewUnstartedServer(handler)
+	tlsServer.EnableHTTP2 = true
+	tlsServer.StartTLS()
+	defer tlsServer.Close()
+
+	// A plain text server that also accepts HTTP/2 with prior knowledge (h2c)
+	h2cServer := httptest.NewUnstartedServer(handler)
+	h2cServer.Config.Protocols = new(http.Protocols)
+	h2cServer.Config.Protocols.SetHTTP1(true)
+	h2cServer.Config.Protocols.SetUnencryptedHTTP2(true)
+	h2cServer.Start()
+	defer h2cServer.Close()
+
+	tests := []struct {
+		name     string
+		command  string
+		pool     PoolOptions
+		expected string
+	}{
+		{
+			name:     "TLS default",
+			command:  `curl -k ` + tlsServer.URL,
+			expected: "2",
+		},
+		{
+			name:     "TLS with --http1.1",
+			command:  `curl -k --http1.1 ` + tlsServer.URL,
+			expected: "1.1",
+		},
+		{
+			name:     "TLS with --http1.0",
+			command:  `curl -k --http1.0 ` + tlsServer.URL,
+			expected: "1.1",
+		},
+		{
+			name:     "TLS with HTTP/2 disabled for the batch",
+			command:  `curl -k ` + tlsServer.URL,
+			pool:     PoolOptions{DisableHTTP2: true},
+			expected: "1.1",
+		},
+		{
+			name:     "--http2 overrides the batch",
+			command:  `curl -k --http2 ` + tlsServer.URL,
+			pool:     PoolOptions{DisableHTTP2: true},
+			expected: "2",
+		},
+		{
+			name:     "Plain text with --http2",
+			command:  `curl --http2 ` + h2cServer.URL,
+			expected: "1.1",
+		},
+		{
+			name:     "Plain text with --http2-prior-knowledge",
+			command:  `curl --http2-prior-knowledge ` + h2cServer.URL,
+			expected: "2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb := &CurlBatch{Pool: tt.pool}
+			defer cb.closeIdleConnections()
+
+			resp, err := cb.executeRequest(mustParseCurlCommand(t, tt.command))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if resp.HTTPVersion != tt.expected {
+				t.Errorf("Expected HTTP version %s, got %s (server saw %s)", tt.expected, resp.HTTPVersion, resp.Body)
+			}
+			if !strings.Contains(resp.String(), "Protocol: HTTP/"+tt.expected+"\n") {
+				t.Errorf("Expected the protocol in the output, got:\n%s", resp)
+			}
+		})
+	}
+}
+
+func TestHTTP10ClosesConnections(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	cb := &CurlBatch{}
+	for range 2 {
+		if _, err := cb.executeRequest(mustParseCurlCommand(t, `curl -0 `+server.URL)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if cb.newConns.Load() != 2 || cb.reusedConns.Load() != 0 {
+		t.Errorf("Expected a new connection per request, got %d new and %d reused",
+			cb.newConns.Load(), cb.reusedConns.Load())
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// parseProxyURL parses a proxy given with -x/--proxy or the -proxy flag.
+// As in curl, the scheme defaults to http and the port to 1080 (443 for https).
+func parseProxyURL(value string) (*url.URL, error) {
+	if !strings.Contains(value, "://") {
+		value = "http://" + value
+	}
+
+	proxyURL, err := url.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy %q: %w", value, err)
+	}
+
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q: use http, https, socks5 or socks5h", proxyURL.Scheme)
+	}
+	if proxyURL.Hostname() == "" {
+		return nil, fmt.Errorf("invalid proxy %q: missing host", value)
+	}
+
+	if proxyURL.Port() == "" {
+		port := "1080"
+		if proxyURL.Scheme == "https" {
+			port = "443"
+		}
+		proxyURL.Host = net.JoinHostPort(proxyURL.Hostname(), port)
+	}
+
+	return proxyURL, nil
+}
+
+// parseProxyUser parses the value of --proxy-user, "user:password"
+func parseProxyUser(value string) (*url.Userinfo, error) {
+	user, password, err := parseUserOption(value)
+	if err != nil {
+		return nil, err
+	}
+	return url.UserPassword(user, password), nil
+}
+
+// noProxyMatch reports whether host is excluded from proxying by a curl
+// style --noproxy list: comma separated host names, which also match their
+// subdomains, IP addresses, CIDR ranges or "*" for every host
+func noProxyMatch(noProxy, host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	ip := net.ParseIP(host)
+
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		entry = strings.TrimSuffix(strings.TrimPrefix(entry, "."), ".")
+		switch {
+		case entry == "":
+			continue
+		case entry == "*":
+			return true
+		case ip != nil && strings.Contains(entry, "/"):
+			if _, network, err := net.ParseCIDR(entry); err == nil && network.Contains(ip) {
+				return true
+			}
+		case host == entry || strings.HasSuffix(host, "."+entry):
+			return true
+		}
+	}
+	return false
+}
+
+// proxySettings returns the proxy and the --noproxy list of a request,
+// letting the -proxy and -noproxy command line options override the template
+func (cb *CurlBatch) proxySettings(creq *curlRequest) (*url.URL, string) {
+	proxyURL := creq.Proxy
+	if cb.Proxy != nil {
+		proxyURL = cb.Proxy
+	}
+	noProxy := creq.NoProxy
+	if cb.NoProxy != "" {
+		noProxy = cb.NoProxy
+	}
+	return proxyURL, noProxy
+}
+
+// noProxyFromEnvironment returns the no_proxy or NO_PROXY environment
+// variable, checked in the same order as curl
+func noProxyFromEnvironment() string {
+	if noProxy := os.Getenv("no_proxy"); noProxy != "" {
+		return noProxy
+	}
+	return os.Getenv("NO_PROXY")
+}
+
+// proxyFunc returns the Proxy function of the transport for a request, or
+// nil if neither the request nor the batch has proxy options and the
+// standard HTTP_PROXY, HTTPS_PROXY and NO_PROXY variables apply as they are.
+// The -proxy and -noproxy flags of the batch override the template. As in
+// curl, NO_PROXY still applies to a proxy given with -x or -proxy unless a
+// --noproxy or -noproxy list replaces it.
+func (cb *CurlBatch) proxyFunc(creq *curlRequest) func(*http.Request) (*url.URL, error) {
+	proxyURL, noProxy := cb.proxySettings(creq)
+	if proxyURL == nil && noProxy == "" && creq.ProxyUser == nil {
+		return nil
+	}
+	if proxyURL != nil && noProxy == "" {
+		noProxy = noProxyFromEnvironment()
+	}
+
+	return func(req *http.Request) (*url.URL, error) {
+		if noProxy != "" && noProxyMatch(noProxy, req.URL.Hostname()) {
+			return nil, nil
+		}
+
+		target := proxyURL
+		if target == nil {
+			envProxy, err := http.ProxyFromEnvironment(req)
+			if err != nil || envProxy == nil {
+				return envProxy, err
+			}
+			target = envProxy
+		}
+
+		if creq.ProxyUser != nil {
+			withUser := *target
+			withUser.User = creq.ProxyUser
+			target = &withUser
+		}
+		return target, nil
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseProxyURL(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+		hasError bool
+	}{
+		{value: "proxy.example.com:8080", expected: "http://proxy.example.com:8080"},
+		{value: "proxy.example.com", expected: "http://proxy.example.com:1080"},
+		{value: "https://proxy.example.com", expected: "https://proxy.example.com:443"},
+		{value: "socks5://127.0.0.1:1080", expected: "socks5://127.0.0.1:1080"},
+		{value: "socks5h://user:pw@proxy.example.com", expected: "socks5h://user:pw@proxy.example.com:1080"},
+		{value: "ftp://proxy.example.com", hasError: true},
+		{value: "http://", hasError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			result, err := parseProxyURL(tt.value)
+
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.String() != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestNoProxyMatch(t *testing.T) {
+	tests := []struct {
+		noProxy  string
+		host     string
+		expected bool
+	}{
+		{noProxy: "*", host: "api.example.com", expected: true},
+		{noProxy: "example.com", host: "example.com", expected: true},
+		{noProxy: "example.com", host: "api.example.com", expected: true},
+		{noProxy: ".example.com", host: "api.example.com", expected: true},
+		{noProxy: "example.com", host: "badexample.com", expected: false},
+		{noProxy: "localhost, internal.corp", host: "INTERNAL.corp", expected: true},
+		{noProxy: "10.0.0.0/8", host: "10.1.2.3", expected: true},
+		{noProxy: "10.0.0.0/8", host: "192.168.0.1", expected: false},
+		{noProxy: "127.0.0.1", host: "127.0.0.1", expected: true},
+		{noProxy: "", host: "example.com", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.noProxy+" "+tt.host, func(t *testing.T) {
+			if got := noProxyMatch(tt.noProxy, tt.host); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestProxyRequests(t *testing.T) {
+	// The proxy receives requests with absolute URLs and answers them itself
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("proxied " + r.URL.Host + " " + r.Header.Get("Proxy-Authorization")))
+	}))
+	defer proxy.Close()
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("direct"))
+	}))
+	defer target.Close()
+
+	const unreachable = "http://127.0.0.1:1"
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte("alice:secret"))
+
+	tests := []struct {
+		name       string
+		command    string
+		proxy      string
+		noProxy    string
+		envNoProxy string
+		expected   string
+	}{
+		{
+			name:     "Proxy from template",
+			command:  `curl -x ` + proxy.URL + ` http://api.example.com/users`,
+			expected: "proxied api.example.com ",
+		},
+		{
+			name:     "Proxy user",
+			command:  `curl --proxy ` + proxy.URL + ` --proxy-user alice:secret http://api.example.com/users`,
+			expected: "proxied api.example.com " + basic,
+		},
+		{
+			name:     "No proxy for matching host",
+			command:  `curl -x ` + unreachable + ` --noproxy 127.0.0.1 ` + target.URL,
+			expected: "direct",
+		},
+		{
+			name:     "Global proxy overrides template",
+			command:  `curl -x ` + unreachable + ` http://api.example.com/users`,
+			proxy:    proxy.URL,
+			expected: "proxied api.example.com ",
+		},
+		{
+			name:     "Global no proxy overrides template",
+			command:  `curl -x ` + unreachable + ` --noproxy other.example.com ` + target.URL,
+			noProxy:  "*",
+			expected: "direct",
+		},
+		{
+			name:       "No proxy from environment",
+			command:    `curl -x ` + unreachable + ` ` + target.URL,
+			envNoProxy: "localhost,127.0.0.1",
+			expected:   "direct",
+		},
+		{
+			name:       "Template no proxy overrides environment",
+			command:    `curl -x ` + proxy.URL + ` --noproxy other.example.com ` + target.URL,
+			envNoProxy: "*",
+			expected:   "proxied " + strings.TrimPrefix(target.URL, "http://") + " ",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("NO_PROXY", tt.envNoProxy)
+			t.Setenv("no_proxy", "")
+
+			cb := &CurlBatch{NoProxy: tt.noProxy}
+			if tt.proxy != "" {
+				proxyURL, err := parseProxyURL(tt.proxy)
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				cb.Proxy = proxyURL
+			}
+
+			resp, err := cb.executeRequest(mustParseCurlCommand(t, tt.command))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if string(resp.Body) != tt.expected {
+				t.Errorf("Expected body %q, got %q", tt.expected, resp.Body)
+			}
+		})
+	}
+}
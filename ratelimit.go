@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket limiter that paces request starts.
+// Tokens are added continuously at the configured rate up to the burst size,
+// and every request start consumes one token.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a RateLimiter allowing perSecond request starts per
+// second with bursts of up to burst requests. The bucket starts full.
+func NewRateLimiter(perSecond float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:   perSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until a request may be started. It returns the context's
+// error if ctx is cancelled first.
+func (rl *RateLimiter) Wait(ctx context.Context) error {
+	if delay := rl.reserve(); delay > 0 {
+		sleepContext(ctx, delay)
+	}
+	return ctx.Err()
+}
+
+// reserve takes one token from the bucket and returns how long the caller
+// must wait before the token becomes available. Tokens may go negative so
+// that concurrent callers are queued at exact intervals.
+func (rl *RateLimiter) reserve() time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	rl.tokens += now.Sub(rl.last).Seconds() * rl.rate
+	if rl.tokens > rl.burst {
+		rl.tokens = rl.burst
+	}
+	rl.last = now
+
+	rl.tokens--
+	if rl.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-rl.tokens / rl.rate * float64(time.Second))
+}
+
+// parseRate parses a rate specification such as "20/s", "100/m" or "3600/h"
+// and returns the number of requests per second. A bare number is
+// interpreted as requests per second.
+func parseRate(spec string) (float64, error) {
+	count, unit, found := strings.Cut(strings.TrimSpace(spec), "/")
+	if !found {
+		unit = "s"
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(count), 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid rate %q: count must be a positive number", spec)
+	}
+
+	var per time.Duration
+	switch strings.TrimSpace(unit) {
+	case "s", "sec", "second":
+		per = time.Second
+	case "m", "min", "minute":
+		per = time.Minute
+	case "h", "hour":
+		per = time.Hour
+	default:
+		return 0, fmt.Errorf("invalid rate %q: unit must be s, m or h", spec)
+	}
+
+	return n / per.Seconds(), nil
+}
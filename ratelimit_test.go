@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     string
+		expected float64
+		hasError bool
+	}{
+		{name: "Per second", spec: "20/s", expected: 20},
+		{name: "Per minute", spec: "120/m", expected: 2},
+		{name: "Per hour", spec: "3600/h", expected: 1},
+		{name: "Bare number", spec: "5", expected: 5},
+		{name: "Fractional", spec: "0.5/s", expected: 0.5},
+		{name: "Long unit", spec: "60/min", expected: 1},
+		{name: "Invalid unit", spec: "10/d", hasError: true},
+		{name: "Invalid count", spec: "abc/s", hasError: true},
+		{name: "Zero", spec: "0/s", hasError: true},
+		{name: "Negative", spec: "-1/s", hasError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parseRate(tt.spec)
+
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+				return
+			}
+
+			if result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestRateLimiterBurst(t *testing.T) {
+	rl := NewRateLimiter(1, 3)
+
+	// The first three requests fit in the burst and must not wait
+	for i := 0; i < 3; i++ {
+		if delay := rl.reserve(); delay != 0 {
+			t.Errorf("Request %d: expected no delay, got %v", i+1, delay)
+		}
+	}
+
+	// The fourth request has to wait for a new token
+	delay := rl.reserve()
+	if delay < 900*time.Millisecond || delay > time.Second {
+		t.Errorf("Expected delay of about 1s, got %v", delay)
+	}
+}
+
+func TestRateLimiterPacesConcurrentCallers(t *testing.T) {
+	rl := NewRateLimiter(100, 1)
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rl.Wait(context.Background())
+		}()
+	}
+	wg.Wait()
+
+	// 10 requests at 100/s with a burst of 1 take at least 90ms
+	if elapsed := time.Since(start); elapsed < 85*time.Millisecond {
+		t.Errorf("Expected requests to be paced over ~90ms, took %v", elapsed)
+	}
+}
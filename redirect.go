@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// defaultMaxRedirs is curl's limit on the number of redirects followed with -L
+const defaultMaxRedirs = 50
+
+// redirectHop records one redirect response that was followed
+type redirectHop struct {
+	StatusCode int    `json:"status_code"`
+	URL        string `json:"url"`
+	Location   string `json:"location"`
+}
+
+// isRedirect reports whether a status code redirects to the Location header
+func isRedirect(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// maxRedirs returns the redirect limit of the request, -1 for no limit
+func (r *curlRequest) maxRedirs() int {
+	if r.MaxRedirs == nil {
+		return defaultMaxRedirs
+	}
+	return *r.MaxRedirs
+}
+
+// redirectRequest returns the request to send after a redirect response,
+// following curl's rules:
+//
+//   - 301 and 302 turn a POST into a GET unless --post301/--post302 is given
+//   - 303 turns every method but HEAD into a GET, unless it is a POST and
+//     --post303 is given
+//   - 307 and 308 repeat the request unchanged
+//   - credentials, the Authorization and Cookie headers given with -H and
+//     the cookies given inline with -b are not sent to a different host;
+//     cookies in the jar follow their own domain rules
+func redirectRequest(creq *curlRequest, code int, location string) (*curlRequest, error) {
+	base, err := url.Parse(creq.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %q: %w", creq.URL, err)
+	}
+	target, err := base.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redirect location %q: %w", location, err)
+	}
+
+	next := *creq
+	next.URL = target.String()
+	next.Header = creq.Header.Clone()
+
+	toGet := false
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound:
+		toGet = creq.Method == http.MethodPost && !creq.KeepPost[code]
+	case http.StatusSeeOther:
+		toGet = creq.Method != http.MethodHead && !(creq.Method == http.MethodPost && creq.KeepPost[code])
+	}
+	if toGet {
+		next.Method = http.MethodGet
+		next.Body = ""
+		next.HasBody = false
+		next.Form = nil
+		next.Header.Del("Content-Type")
+		next.Header.Del("Content-Length")
+	}
+
+	if !strings.EqualFold(base.Scheme, target.Scheme) || !strings.EqualFold(base.Host, target.Host) {
+		next.User, next.Password, next.BearerToken = "", "", ""
+		next.Cookie = ""
+		next.Header.Del("Authorization")
+		next.Header.Del("Cookie")
+	}
+
+	return &next, nil
+}
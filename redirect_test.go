@@ -0,0 +1,169 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRedirects(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("other auth=" + r.Header.Get("Authorization")))
+	}))
+	defer other.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write([]byte(r.Method + " " + string(body) + " auth=" + r.Header.Get("Authorization")))
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/chain", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/301", http.StatusFound)
+	})
+	mux.HandleFunc("/other", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other.URL+"/", http.StatusFound)
+	})
+	for _, code := range []int{301, 302, 303, 307, 308} {
+		mux.HandleFunc("/"+strconv.Itoa(code), func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/echo", code)
+		})
+	}
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	path := func(code int) string {
+		return server.URL + "/" + strconv.Itoa(code)
+	}
+
+	tests := []struct {
+		name      string
+		command   string
+		status    int
+		body      string
+		redirects []redirectHop
+		errText   string
+	}{
+		{
+			name:    "Not followed without -L",
+			command: `curl ` + path(302),
+			status:  http.StatusFound,
+		},
+		{
+			name:    "Followed with -L",
+			command: `curl -L ` + path(302),
+			status:  http.StatusOK,
+			body:    "GET  auth=",
+			redirects: []redirectHop{
+				{StatusCode: 302, URL: path(302), Location: server.URL + "/echo"},
+			},
+		},
+		{
+			name:    "POST becomes GET after 301",
+			command: `curl -L -d a=1 ` + path(301),
+			status:  http.StatusOK,
+			body:    "GET  auth=",
+		},
+		{
+			name:    "POST kept after 301 with --post301",
+			command: `curl -L --post301 -d a=1 ` + path(301),
+			status:  http.StatusOK,
+			body:    "POST a=1 auth=",
+		},
+		{
+			name:    "POST kept after 302 with --post302",
+			command: `curl -L --post302 -d a=1 ` + path(302),
+			status:  http.StatusOK,
+			body:    "POST a=1 auth=",
+		},
+		{
+			name:    "PUT becomes GET after 303",
+			command: `curl -L -X PUT -d a=1 ` + path(303),
+			status:  http.StatusOK,
+			body:    "GET  auth=",
+		},
+		{
+			name:    "POST kept after 303 with --post303",
+			command: `curl -L --post303 -d a=1 ` + path(303),
+			status:  http.StatusOK,
+			body:    "POST a=1 auth=",
+		},
+		{
+			name:    "POST kept after 307",
+			command: `curl -L -d a=1 ` + path(307),
+			status:  http.StatusOK,
+			body:    "POST a=1 auth=",
+		},
+		{
+			name:    "PUT kept after 308",
+			command: `curl -L -X PUT -d a=1 ` + path(308),
+			status:  http.StatusOK,
+			body:    "PUT a=1 auth=",
+		},
+		{
+			name:    "Chain of redirects",
+			command: `curl -L ` + server.URL + `/chain`,
+			status:  http.StatusOK,
+			body:    "GET  auth=",
+			redirects: []redirectHop{
+				{StatusCode: 302, URL: server.URL + "/chain", Location: server.URL + "/301"},
+				{StatusCode: 301, URL: server.URL + "/301", Location: server.URL + "/echo"},
+			},
+		},
+		{
+			name:    "Credentials kept on the same host",
+			command: `curl -L -u alice:secret ` + path(302),
+			status:  http.StatusOK,
+			body:    "GET  auth=Basic YWxpY2U6c2VjcmV0",
+		},
+		{
+			name:    "Credentials dropped for another host",
+			command: `curl -L -u alice:secret -H "Authorization: Token x" ` + server.URL + `/other`,
+			status:  http.StatusOK,
+			body:    "other auth=",
+		},
+		{
+			name:    "Maximum redirects",
+			command: `curl -L --max-redirs 3 ` + server.URL + `/loop`,
+			errText: "maximum (3) redirects followed",
+		},
+		{
+			name:    "No redirects allowed",
+			command: `curl -L --max-redirs 0 ` + path(302),
+			errText: "maximum (0) redirects followed",
+		},
+	}
+
+	cb := &CurlBatch{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := cb.executeRequest(mustParseCurlCommand(t, tt.command))
+
+			if tt.errText != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errText) {
+					t.Errorf("Expected error containing %q, got %v", tt.errText, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, resp.StatusCode)
+			}
+			if tt.body != "" && string(resp.Body) != tt.body {
+				t.Errorf("Expected body %q, got %q", tt.body, resp.Body)
+			}
+			if tt.redirects != nil && !reflect.DeepEqual(resp.Redirects, tt.redirects) {
+				t.Errorf("Expected redirects %+v, got %+v", tt.redirects, resp.Redirects)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy describes when and how often a failed request is retried
+type RetryPolicy struct {
+	MaxAttempts    int
+	BaseBackoff    time.Duration
+	MaxBackoff     time.Duration
+	Jitter         float64 // fraction of the backoff that is randomised, 0-1
+	RetryStatus    map[int]bool
+	RetryNetErrors bool
+}
+
+// attemptResult records the outcome of a single attempt of a request
+type attemptResult struct {
+	Response *httpResponse
+	Err      error
+	Wait     time.Duration // time waited before the next attempt, 0 for the last one
+}
+
+// shouldRetry reports whether an attempt with the given outcome is retryable
+func (p *RetryPolicy) shouldRetry(resp *httpResponse, err error) bool {
+	if err != nil {
+		var netErr *networkError
+		return p.RetryNetErrors && errors.As(err, &netErr)
+	}
+	return p.RetryStatus[resp.StatusCode]
+}
+
+// backoff returns how long to wait before the attempt following attempt
+// (1-based). A Retry-After header on a 429 or 503 response takes precedence
+// over the exponential backoff.
+func (p *RetryPolicy) backoff(attempt int, resp *httpResponse) time.Duration {
+	if resp != nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) {
+		if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			return wait
+		}
+	}
+
+	wait := p.BaseBackoff
+	for i := 1; i < attempt; i++ {
+		wait *= 2
+		if p.MaxBackoff > 0 && wait >= p.MaxBackoff {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+		wait = p.MaxBackoff
+	}
+
+	if p.Jitter > 0 {
+		wait -= time.Duration(rand.Float64() * p.Jitter * float64(wait))
+	}
+
+	return wait
+}
+
+// parseRetryAfter parses a Retry-After header value, which is either a
+// number of seconds or an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		wait := date.Sub(now)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+
+	return 0, false
+}
+
+// parseStatusList parses a comma separated list of HTTP status codes
+func parseStatusList(list string) (map[int]bool, error) {
+	codes := make(map[int]bool)
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		code, err := strconv.Atoi(field)
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("invalid status code %q", field)
+		}
+		codes[code] = true
+	}
+	return codes, nil
+}
+
+// executeWithRetry executes a curl request, retrying it according to the
+// batch's retry policy. Every attempt is returned, the last one being the
+// final outcome. No further attempts are started once ctx is cancelled, so
+// the result is empty if ctx was cancelled before the first attempt.
+func (cb *CurlBatch) executeWithRetry(ctx context.Context, req *curlRequest) []attemptResult {
+	maxAttempts := 1
+	if cb.Retry != nil && cb.Retry.MaxAttempts > 1 {
+		maxAttempts = cb.Retry.MaxAttempts
+	}
+
+	var attempts []attemptResult
+	for attempt := 1; ; attempt++ {
+		if cb.RateLimiter != nil {
+			if err := cb.RateLimiter.Wait(ctx); err != nil {
+				return attempts
+			}
+		} else if ctx.Err() != nil {
+			return attempts
+		}
+
+		resp, err := cb.executeRequest(req)
+		attempts = append(attempts, attemptResult{Response: resp, Err: err})
+
+		if attempt >= maxAttempts || !cb.Retry.shouldRetry(resp, err) {
+			return attempts
+		}
+
+		wait := cb.Retry.backoff(attempt, resp)
+		if !sleepContext(ctx, wait) {
+			return attempts
+		}
+		attempts[len(attempts)-1].Wait = wait
+	}
+}
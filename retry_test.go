@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 7, 27, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{name: "Seconds", value: "120", expected: 120 * time.Second, ok: true},
+		{name: "Zero seconds", value: "0", expected: 0, ok: true},
+		{name: "HTTP date", value: "Sun, 27 Jul 2025 12:00:30 GMT", expected: 30 * time.Second, ok: true},
+		{name: "HTTP date in the past", value: "Sun, 27 Jul 2025 11:00:00 GMT", expected: 0, ok: true},
+		{name: "Empty", value: "", ok: false},
+		{name: "Negative", value: "-5", ok: false},
+		{name: "Garbage", value: "soon", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, ok := parseRetryAfter(tt.value, now)
+			if ok != tt.ok {
+				t.Fatalf("Expected ok=%v, got %v", tt.ok, ok)
+			}
+			if result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestParseStatusList(t *testing.T) {
+	codes, err := parseStatusList("429, 502,503,504")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, code := range []int{429, 502, 503, 504} {
+		if !codes[code] {
+			t.Errorf("Expected %d in status list", code)
+		}
+	}
+	if codes[500] {
+		t.Error("Did not expect 500 in status list")
+	}
+
+	for _, list := range []string{"abc", "42", "600"} {
+		if _, err := parseStatusList(list); err == nil {
+			t.Errorf("Expected error for %q", list)
+		}
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := &RetryPolicy{BaseBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	expected := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}
+	for i, want := range expected {
+		if got := p.backoff(i+1, nil); got != want {
+			t.Errorf("Attempt %d: expected %v, got %v", i+1, want, got)
+		}
+	}
+
+	// Retry-After takes precedence on 429 and 503 responses
+	resp := &httpResponse{StatusCode: 429, Header: http.Header{"Retry-After": {"7"}}}
+	if got := p.backoff(1, resp); got != 7*time.Second {
+		t.Errorf("Expected Retry-After of 7s to be honoured, got %v", got)
+	}
+
+	// ...but not on other status codes
+	resp = &httpResponse{StatusCode: 502, Header: http.Header{"Retry-After": {"7"}}}
+	if got := p.backoff(1, resp); got != 100*time.Millisecond {
+		t.Errorf("Expected Retry-After to be ignored on 502, got %v", got)
+	}
+
+	// Jitter only ever shortens the backoff
+	p.Jitter = 0.5
+	for i := 0; i < 20; i++ {
+		got := p.backoff(2, nil)
+		if got < 100*time.Millisecond || got > 200*time.Millisecond {
+			t.Fatalf("Expected jittered backoff between 100ms and 200ms, got %v", got)
+		}
+	}
+}
+
+func TestExecuteWithRetry(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	cb := &CurlBatch{
+		Retry: &RetryPolicy{
+			MaxAttempts: 5,
+			BaseBackoff: time.Hour, // must not be used because of Retry-After
+			RetryStatus: map[int]bool{503: true},
+		},
+	}
+
+	attempts := cb.executeWithRetry(context.Background(), mustParseCurlCommand(t, "curl "+server.URL))
+	if len(attempts) != 3 {
+		t.Fatalf("Expected 3 attempts, got %d", len(attempts))
+	}
+	for i, attempt := range attempts[:2] {
+		if attempt.Err != nil || attempt.Response.StatusCode != 503 {
+			t.Errorf("Attempt %d: expected 503, got %+v", i+1, attempt)
+		}
+	}
+	final := attempts[2]
+	if final.Err != nil || final.Response.StatusCode != 200 || string(final.Response.Body) != "ok" {
+		t.Errorf("Expected final attempt to succeed, got %+v", final)
+	}
+}
+
+func TestExecuteWithRetryGivesUp(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	cb := &CurlBatch{
+		Retry: &RetryPolicy{
+			MaxAttempts: 3,
+			BaseBackoff: time.Millisecond,
+			RetryStatus: map[int]bool{502: true},
+		},
+	}
+
+	attempts := cb.executeWithRetry(context.Background(), mustParseCurlCommand(t, "curl "+server.URL))
+	if len(attempts) != 3 || atomic.LoadInt32(&calls) != 3 {
+		t.Fatalf("Expected 3 attempts, got %d (%d calls)", len(attempts), calls)
+	}
+	if attempts[2].Wait != 0 {
+		t.Errorf("Expected no wait after the last attempt, got %v", attempts[2].Wait)
+	}
+}
+
+func TestExecuteWithRetryDoesNotRetryCommandErrors(t *testing.T) {
+	cb := &CurlBatch{
+		Retry: &RetryPolicy{
+			MaxAttempts:    3,
+			BaseBackoff:    time.Millisecond,
+			RetryNetErrors: true,
+		},
+	}
+
+	attempts := cb.executeWithRetry(context.Background(), mustParseCurlCommand(t, "curl http://[::1"))
+	if len(attempts) != 1 {
+		t.Fatalf("Expected a single attempt, got %d", len(attempts))
+	}
+	if attempts[0].Err == nil || !strings.Contains(attempts[0].Err.Error(), "failed to create request") {
+		t.Errorf("Expected failed to create request error, got %v", attempts[0].Err)
+	}
+}
+
+func TestExecuteWithRetryNetworkError(t *testing.T) {
+	// Grab a free port and close it so connections are refused
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	cb := &CurlBatch{
+		Retry: &RetryPolicy{
+			MaxAttempts:    2,
+			BaseBackoff:    time.Millisecond,
+			RetryNetErrors: true,
+		},
+	}
+
+	attempts := cb.executeWithRetry(context.Background(), mustParseCurlCommand(t, "curl "+url))
+	if len(attempts) != 2 {
+		t.Fatalf("Expected 2 attempts, got %d", len(attempts))
+	}
+	if attempts[1].Err == nil {
+		t.Error("Expected final attempt to fail")
+	}
+}
+
+// mustParseCurlCommand parses a curl command, failing the test on error
+func mustParseCurlCommand(t *testing.T, command string) *curlRequest {
+	t.Helper()
+	req, err := parseCurlCommand(command)
+	if err != nil {
+		t.Fatalf("parseCurlCommand(%q) failed: %v", command, err)
+	}
+	return req
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"time"
+)
+
+// Summary collects the outcome of a batch run
+type Summary struct {
+	Total            int
+	Succeeded        int
+	Failed           int
+	AssertionsFailed int // rows among Failed whose assertions did not hold
+	TimedOut         int // rows among Failed whose request timed out
+	Retried          int // rows that needed more than one attempt
+	Skipped          int
+	Interrupted      bool
+	StatusCodes      map[int]int
+	Latencies        []time.Duration // duration of the final attempt of every row with a response
+	Elapsed          time.Duration
+
+	// Connections opened by requests and connections reused from the pool,
+	// over every attempt, redirect and digest challenge
+	NewConnections    int
+	ReusedConnections int
+}
+
+// add accounts for the result of a single row
+func (s *Summary) add(r *rowResult) {
+	switch {
+	case r.Skipped:
+		s.Skipped++
+		return
+	case r.failed():
+		s.Failed++
+		if len(r.Failures) > 0 {
+			s.AssertionsFailed++
+		}
+		if r.timeout() != "" {
+			s.TimedOut++
+		}
+	default:
+		s.Succeeded++
+	}
+
+	if len(r.Attempts) > 1 {
+		s.Retried++
+	}
+
+	if r.Response != nil {
+		if s.StatusCodes == nil {
+			s.StatusCodes = make(map[int]int)
+		}
+		s.StatusCodes[r.Response.StatusCode]++
+		s.Latencies = append(s.Latencies, r.Response.Duration)
+	}
+}
+
+// TotalLatency returns the sum of all request latencies
+func (s *Summary) TotalLatency() time.Duration {
+	var total time.Duration
+	for _, d := range s.Latencies {
+		total += d
+	}
+	return total
+}
+
+// AverageLatency returns the mean request latency
+func (s *Summary) AverageLatency() time.Duration {
+	if len(s.Latencies) == 0 {
+		return 0
+	}
+	return s.TotalLatency() / time.Duration(len(s.Latencies))
+}
+
+// PercentileLatency returns the p-th percentile (0-100) request latency
+// using the nearest-rank method
+func (s *Summary) PercentileLatency(p float64) time.Duration {
+	if len(s.Latencies) == 0 {
+		return 0
+	}
+
+	sorted := slices.Clone(s.Latencies)
+	slices.Sort(sorted)
+
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	rank = max(0, min(rank, len(sorted)-1))
+	return sorted[rank]
+}
+
+// Print writes a human readable summary to w
+func (s *Summary) Print(w io.Writer) {
+	fmt.Fprintf(w, "\n=== Summary ===\n")
+	if s.Interrupted {
+		fmt.Fprintf(w, "Batch was interrupted before all rows were executed\n")
+	}
+	fmt.Fprintf(w, "Total:     %d\n", s.Total)
+	fmt.Fprintf(w, "Succeeded: %d\n", s.Succeeded)
+	fmt.Fprintf(w, "Failed:    %d", s.Failed)
+	if s.AssertionsFailed > 0 {
+		fmt.Fprintf(w, " (assertions failed: %d)", s.AssertionsFailed)
+	}
+	if s.TimedOut > 0 {
+		fmt.Fprintf(w, " (timed out: %d)", s.TimedOut)
+	}
+	fmt.Fprintf(w, "\n")
+	fmt.Fprintf(w, "Retried:   %d\n", s.Retried)
+	fmt.Fprintf(w, "Skipped:   %d\n", s.Skipped)
+
+	if len(s.StatusCodes) > 0 {
+		fmt.Fprintf(w, "Status codes:\n")
+		codes := make([]int, 0, len(s.StatusCodes))
+		for code := range s.StatusCodes {
+			codes = append(codes, code)
+		}
+		slices.Sort(codes)
+		for _, code := range codes {
+			fmt.Fprintf(w, "  %d: %d\n", code, s.StatusCodes[code])
+		}
+	}
+
+	if len(s.Latencies) > 0 {
+		fmt.Fprintf(w, "Latency:   total %s, avg %s, p95 %s\n",
+			s.TotalLatency().Round(time.Millisecond),
+			s.AverageLatency().Round(time.Millisecond),
+			s.PercentileLatency(95).Round(time.Millisecond))
+	}
+	if total := s.NewConnections + s.ReusedConnections; total > 0 {
+		fmt.Fprintf(w, "Connections: %d new, %d reused (%.0f%% reuse)\n",
+			s.NewConnections, s.ReusedConnections, float64(s.ReusedConnections)/float64(total)*100)
+	}
+	fmt.Fprintf(w, "Elapsed:   %s\n", s.Elapsed.Round(time.Millisecond))
+}
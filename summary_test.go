@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSummaryAdd(t *testing.T) {
+	s := &Summary{Total: 6}
+
+	results := []*rowResult{
+		{Response: &httpResponse{StatusCode: 200, Duration: 10 * time.Millisecond}, Attempts: []attemptResult{{}}},
+		{Response: &httpResponse{StatusCode: 200, Duration: 30 * time.Millisecond}, Attempts: []attemptResult{{}, {}}},
+		{Response: &httpResponse{StatusCode: 500, Duration: 20 * time.Millisecond}, Attempts: []attemptResult{{}}, Failures: []string{"expected status 2xx, got 500"}},
+		{Err: errors.New("request failed"), Attempts: []attemptResult{{}, {}, {}}},
+		{Skipped: true},
+	}
+	for _, r := range results {
+		s.add(r)
+	}
+
+	if s.Succeeded != 2 || s.Failed != 2 || s.AssertionsFailed != 1 || s.Retried != 2 || s.Skipped != 1 {
+		t.Errorf("Unexpected counts: %+v", s)
+	}
+	if s.StatusCodes[200] != 2 || s.StatusCodes[500] != 1 || len(s.StatusCodes) != 2 {
+		t.Errorf("Unexpected status code histogram: %v", s.StatusCodes)
+	}
+	if s.TotalLatency() != 60*time.Millisecond {
+		t.Errorf("Expected total latency 60ms, got %v", s.TotalLatency())
+	}
+	if s.AverageLatency() != 20*time.Millisecond {
+		t.Errorf("Expected average latency 20ms, got %v", s.AverageLatency())
+	}
+}
+
+func TestSummaryPercentileLatency(t *testing.T) {
+	s := &Summary{}
+	if s.PercentileLatency(95) != 0 {
+		t.Error("Expected 0 without latencies")
+	}
+
+	for i := 100; i >= 1; i-- {
+		s.Latencies = append(s.Latencies, time.Duration(i)*time.Millisecond)
+	}
+
+	tests := []struct {
+		p        float64
+		expected time.Duration
+	}{
+		{p: 95, expected: 95 * time.Millisecond},
+		{p: 50, expected: 50 * time.Millisecond},
+		{p: 100, expected: 100 * time.Millisecond},
+		{p: 0, expected: time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := s.PercentileLatency(tt.p); got != tt.expected {
+			t.Errorf("p%v: expected %v, got %v", tt.p, tt.expected, got)
+		}
+	}
+
+	// Percentile calculation must not reorder the recorded latencies
+	if s.Latencies[0] != 100*time.Millisecond {
+		t.Error("Expected latencies to be left unsorted")
+	}
+}
+
+func TestSummaryPrint(t *testing.T) {
+	s := &Summary{
+		Total:       3,
+		Succeeded:   2,
+		Failed:      1,
+		StatusCodes: map[int]int{201: 2, 500: 1},
+		Latencies:   []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond},
+		Elapsed:     time.Second,
+
+		NewConnections:    1,
+		ReusedConnections: 3,
+	}
+
+	var b strings.Builder
+	s.Print(&b)
+
+	for _, want := range []string{
+		"Total:     3\n",
+		"Succeeded: 2\n",
+		"Failed:    1\n",
+		"  201: 2\n  500: 1\n",
+		"Latency:   total 60ms, avg 20ms, p95 30ms\n",
+		"Connections: 1 new, 3 reused (75% reuse)\n",
+		"Elapsed:   1s\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("Expected summary to contain %q, got:\n%s", want, b.String())
+		}
+	}
+}
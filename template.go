@@ -6,13 +6,16 @@ import (
 	"strings"
 )
 
-// readCurlTemplate reads a curl template file and returns its content
+// readCurlTemplate reads a curl template file and returns its content.
+// CRLF line endings are converted to LF so that line continuations work in
+// files edited on Windows; comments and continuations are left for
+// splitCurlCommand.
 func readCurlTemplate(filename string) (string, error) {
 	content, err := os.ReadFile(filename)
 	if err != nil {
 		return "", err
 	}
-	return strings.TrimSpace(string(content)), nil
+	return strings.TrimSpace(strings.ReplaceAll(string(content), "\r\n", "\n")), nil
 }
 
 // replaceTemplate replaces variables in the template with values from data
@@ -58,6 +58,40 @@ func TestReadCurlTemplateWithWhitespace(t *testing.T) {
 	}
 }
 
+func TestReadCurlTemplateCRLF(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "curl_template_*.txt")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+
+	content := "# Create a user\r\ncurl -X POST \\\r\n  -d 'name=${NAME}' \\\r\n  https://api.example.com\r\n"
+	expected := "# Create a user\ncurl -X POST \\\n  -d 'name=${NAME}' \\\n  https://api.example.com"
+
+	_, err = tmpFile.WriteString(content)
+	if err != nil {
+		t.Fatalf("Failed to write to temp file: %v", err)
+	}
+	tmpFile.Close()
+
+	result, err := readCurlTemplate(tmpFile.Name())
+	if err != nil {
+		t.Fatalf("readCurlTemplate failed: %v", err)
+	}
+
+	if result != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+
+	parts, err := splitCurlCommand(result)
+	if err != nil {
+		t.Fatalf("splitCurlCommand failed: %v", err)
+	}
+	if len(parts) != 6 {
+		t.Errorf("Expected 6 arguments, got %q", parts)
+	}
+}
+
 func TestReadCurlTemplateFileNotExists(t *testing.T) {
 	_, err := readCurlTemplate("nonexistent_file.txt")
 	if err == nil {
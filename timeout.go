@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Kinds of timeoutError
+const (
+	timeoutConnect = "connect"  // --connect-timeout
+	timeoutMaxTime = "max_time" // -m/--max-time
+)
+
+// timeoutError is returned by executeRequest when a request exceeds its
+// connect timeout or its maximum time. It is wrapped in a networkError, so
+// timed out requests are retried like other transport failures.
+type timeoutError struct {
+	Kind    string
+	Timeout time.Duration // zero if the transport's own connect timeout fired
+	err     error
+}
+
+func (e *timeoutError) Error() string {
+	what := "connection"
+	if e.Kind == timeoutMaxTime {
+		what = "operation"
+	}
+	if e.Timeout == 0 {
+		return what + " timed out"
+	}
+	return fmt.Sprintf("%s timed out after %s", what, e.Timeout)
+}
+
+func (e *timeoutError) Unwrap() error {
+	return e.err
+}
+
+// parseSeconds parses a curl timeout given in seconds, e.g. "2" or "0.5"
+func parseSeconds(value string) (time.Duration, error) {
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || seconds < 0 {
+		return 0, fmt.Errorf("invalid number of seconds %q", value)
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// timeouts returns the connect timeout and the maximum time of a request.
+// Values given in the template take precedence over the batch defaults,
+// including an explicit 0. Zero means no limit (for the connect timeout,
+// the transport's default).
+func (cb *CurlBatch) timeouts(creq *curlRequest) (connect, maxTime time.Duration) {
+	connect, maxTime = cb.ConnectTimeout, cb.MaxTime
+	if creq.ConnectTimeout != nil {
+		connect = *creq.ConnectTimeout
+	}
+	if creq.MaxTime != nil {
+		maxTime = *creq.MaxTime
+	}
+	return connect, maxTime
+}
+
+// requestError wraps an error of a request sent with ctx, which carries the
+// request's maximum time, telling timeouts apart from other failures
+func requestError(ctx context.Context, err error, message string, connect, maxTime time.Duration) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &networkError{&timeoutError{Kind: timeoutMaxTime, Timeout: maxTime, err: err}}
+	}
+
+	// With the maximum time enforced by ctx, the remaining timeouts are
+	// those of the connection phase: dialing and the TLS handshake
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return &networkError{&timeoutError{Kind: timeoutConnect, Timeout: connect, err: err}}
+	}
+
+	return &networkError{fmt.Errorf("%s: %w", message, err)}
+}
+
+// timeout returns the kind of timeout the row failed with, or ""
+func (r *rowResult) timeout() string {
+	var timeoutErr *timeoutError
+	if errors.As(r.Err, &timeoutErr) {
+		return timeoutErr.Kind
+	}
+	return ""
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTimeoutOptions(t *testing.T) {
+	req := mustParseCurlCommand(t, `curl --connect-timeout 2.5 -m 10 https://api.example.com`)
+	if req.ConnectTimeout == nil || *req.ConnectTimeout != 2500*time.Millisecond {
+		t.Errorf("Expected connect timeout 2.5s, got %v", req.ConnectTimeout)
+	}
+	if req.MaxTime == nil || *req.MaxTime != 10*time.Second {
+		t.Errorf("Expected max time 10s, got %v", req.MaxTime)
+	}
+
+	req = mustParseCurlCommand(t, `curl https://api.example.com`)
+	if req.ConnectTimeout != nil || req.MaxTime != nil {
+		t.Errorf("Expected no timeouts without options, got %v and %v", req.ConnectTimeout, req.MaxTime)
+	}
+
+	for _, command := range []string{
+		`curl --max-time abc https://api.example.com`,
+		`curl --connect-timeout -1 https://api.example.com`,
+	} {
+		if _, err := parseCurlCommand(command); err == nil {
+			t.Errorf("Expected error for %s", command)
+		}
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+	cb := &CurlBatch{ConnectTimeout: 2 * time.Second, MaxTime: 30 * time.Second}
+
+	tests := []struct {
+		command string
+		connect time.Duration
+		maxTime time.Duration
+	}{
+		{`curl https://api.example.com`, 2 * time.Second, 30 * time.Second},
+		{`curl --connect-timeout 5 -m 10 https://api.example.com`, 5 * time.Second, 10 * time.Second},
+		{`curl --connect-timeout 0 -m 0 https://api.example.com`, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			connect, maxTime := cb.timeouts(mustParseCurlCommand(t, tt.command))
+			if connect != tt.connect || maxTime != tt.maxTime {
+				t.Errorf("Expected timeouts %s and %s, got %s and %s", tt.connect, tt.maxTime, connect, maxTime)
+			}
+		})
+	}
+}
+
+func TestMaxTime(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		w.Write([]byte("slow"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		options string
+		maxTime time.Duration
+		timeout string
+	}{
+		{
+			name:    "Template max time",
+			options: "-m 0.05",
+			timeout: timeoutMaxTime,
+		},
+		{
+			name:    "Batch default",
+			maxTime: 50 * time.Millisecond,
+			timeout: timeoutMaxTime,
+		},
+		{
+			name:    "Template overrides batch default",
+			options: "--max-time 5",
+			maxTime: 50 * time.Millisecond,
+		},
+		{
+			name:    "Template disables batch default",
+			options: "-m 0",
+			maxTime: 50 * time.Millisecond,
+		},
+		{
+			name: "No limit",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb := &CurlBatch{MaxTime: tt.maxTime}
+			_, err := cb.executeRequest(mustParseCurlCommand(t, "curl "+tt.options+" "+server.URL))
+
+			r := &rowResult{Err: err}
+			if r.timeout() != tt.timeout {
+				t.Errorf("Expected timeout %q, got %q (error: %v)", tt.timeout, r.timeout(), err)
+			}
+			if tt.timeout == "" {
+				return
+			}
+
+			var netErr *networkError
+			if !errors.As(err, &netErr) {
+				t.Errorf("Expected timeouts to be retryable network errors, got %T", err)
+			}
+			if !strings.Contains(err.Error(), "operation timed out after 50ms") {
+				t.Errorf("Unexpected error message: %v", err)
+			}
+		})
+	}
+}
+
+func TestConnectTimeout(t *testing.T) {
+	// A listener that accepts connections but never answers the TLS handshake
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	cb := &CurlBatch{MaxTime: 5 * time.Second}
+	_, err = cb.executeRequest(mustParseCurlCommand(t, "curl --connect-timeout 0.1 https://"+listener.Addr().String()))
+
+	r := &rowResult{Err: err}
+	if r.timeout() != timeoutConnect {
+		t.Fatalf("Expected a connect timeout, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "connection timed out after 100ms") {
+		t.Errorf("Unexpected error message: %v", err)
+	}
+
+	summary := &Summary{}
+	summary.add(r)
+	if summary.Failed != 1 || summary.TimedOut != 1 {
+		t.Errorf("Expected 1 failed and timed out row, got %d failed and %d timed out", summary.Failed, summary.TimedOut)
+	}
+}
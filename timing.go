@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// requestTiming is the timing breakdown of a request. Like curl's -w
+// variables, every value is measured from the start of the request, so
+// each includes the ones before it. With redirects and digest challenges
+// the values of all requests are added up, and Redirect holds the part
+// spent on redirect responses.
+type requestTiming struct {
+	NameLookup    time.Duration // time_namelookup: DNS lookup done
+	Connect       time.Duration // time_connect: TCP connection established
+	AppConnect    time.Duration // time_appconnect: TLS handshake done, zero without a handshake
+	PreTransfer   time.Duration // time_pretransfer: about to send the request
+	StartTransfer time.Duration // time_starttransfer: first response byte received
+	Total         time.Duration // time_total: response body read
+	Redirect      time.Duration // time_redirect: spent on redirects before the final request
+}
+
+// variable returns a timing value by its curl -w variable name
+func (t *requestTiming) variable(name string) time.Duration {
+	switch name {
+	case "time_namelookup":
+		return t.NameLookup
+	case "time_connect":
+		return t.Connect
+	case "time_appconnect":
+		return t.AppConnect
+	case "time_pretransfer":
+		return t.PreTransfer
+	case "time_starttransfer":
+		return t.StartTransfer
+	case "time_redirect":
+		return t.Redirect
+	case "time_total":
+		return t.Total
+	}
+	return 0
+}
+
+// String formats the timing as the durations of the phases of the request:
+// the DNS lookup, TCP connect and TLS handshake, the time to the first
+// response byte and the content transfer after it
+func (t requestTiming) String() string {
+	var tlsHandshake time.Duration
+	if t.AppConnect > 0 {
+		tlsHandshake = t.AppConnect - t.Connect
+	}
+
+	s := fmt.Sprintf("dns %s, connect %s, tls %s, ttfb %s, transfer %s, total %s",
+		milliseconds(t.NameLookup), milliseconds(t.Connect-t.NameLookup), milliseconds(tlsHandshake),
+		milliseconds(t.StartTransfer), milliseconds(t.Total-t.StartTransfer), milliseconds(t.Total))
+	if t.Redirect > 0 {
+		s += ", redirects " + milliseconds(t.Redirect)
+	}
+	return s
+}
+
+// milliseconds formats a duration in milliseconds, e.g. "12.345ms"
+func milliseconds(d time.Duration) string {
+	return strconv.FormatFloat(durationMs(d), 'f', 3, 64) + "ms"
+}
+
+// seconds formats a timing value like curl's -w output, e.g. "0.012345"
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 6, 64)
+}
+
+// timingColumn returns the -columns function of a curl -w timing variable
+func timingColumn(name string) func(r *rowResult) string {
+	return func(r *rowResult) string {
+		if r.Response == nil {
+			return ""
+		}
+		return seconds(r.Response.Timing.variable(name))
+	}
+}
+
+// jsonlTiming is the timing object of jsonlRecord, in seconds
+type jsonlTiming struct {
+	NameLookup    float64 `json:"time_namelookup"`
+	Connect       float64 `json:"time_connect"`
+	AppConnect    float64 `json:"time_appconnect"`
+	PreTransfer   float64 `json:"time_pretransfer"`
+	StartTransfer float64 `json:"time_starttransfer"`
+	Redirect      float64 `json:"time_redirect"`
+	Total         float64 `json:"time_total"`
+}
+
+// newJSONLTiming converts a timing to seconds with microsecond precision
+func newJSONLTiming(t requestTiming) *jsonlTiming {
+	s := func(d time.Duration) float64 {
+		return float64(d.Microseconds()) / 1e6
+	}
+	return &jsonlTiming{
+		NameLookup:    s(t.NameLookup),
+		Connect:       s(t.Connect),
+		AppConnect:    s(t.AppConnect),
+		PreTransfer:   s(t.PreTransfer),
+		StartTransfer: s(t.StartTransfer),
+		Redirect:      s(t.Redirect),
+		Total:         s(t.Total),
+	}
+}
+
+// requestTimer records the phases of the requests sent for one operation
+// with an httptrace.ClientTrace. The trace callbacks can run on the
+// transport's dialing goroutines, hence the mutex.
+type requestTimer struct {
+	mu     sync.Mutex
+	timing requestTiming
+
+	start     time.Time
+	dnsDone   time.Time
+	connected time.Time
+	tlsDone   time.Time
+	gotConn   time.Time
+	firstByte time.Time
+}
+
+// begin marks the start of a request and returns ctx with the trace that
+// records its phases
+func (t *requestTimer) begin(ctx context.Context) context.Context {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.start = time.Now()
+	t.dnsDone, t.connected, t.tlsDone, t.gotConn, t.firstByte = time.Time{}, time.Time{}, time.Time{}, time.Time{}, time.Time{}
+
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mark(&t.dnsDone)
+		},
+		ConnectDone: func(network, addr string, err error) {
+			if err == nil {
+				t.mark(&t.connected)
+			}
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			if err == nil {
+				t.mark(&t.tlsDone)
+			}
+		},
+		GotConn: func(httptrace.GotConnInfo) {
+			t.mark(&t.gotConn)
+		},
+		GotFirstResponseByte: func() {
+			t.mark(&t.firstByte)
+		},
+	})
+}
+
+// mark records the time a phase ended
+func (t *requestTimer) mark(at *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	*at = time.Now()
+}
+
+// end adds the phases of the request started by the last call to begin,
+// once its response body has been read. Phases that did not happen, such
+// as the DNS lookup and connect on a reused connection, take the value of
+// the phase before them.
+func (t *requestTimer) end(redirect bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	since := func(at time.Time, previous time.Duration) time.Duration {
+		if at.IsZero() || at.Before(t.start) {
+			return previous
+		}
+		return max(at.Sub(t.start), previous)
+	}
+
+	nameLookup := since(t.dnsDone, 0)
+	connect := since(t.connected, nameLookup)
+	var appConnect time.Duration
+	if !t.tlsDone.IsZero() {
+		appConnect = since(t.tlsDone, connect)
+	}
+	preTransfer := since(t.gotConn, max(connect, appConnect))
+	startTransfer := since(t.firstByte, preTransfer)
+	total := max(time.Since(t.start), startTransfer)
+
+	t.timing.NameLookup += nameLookup
+	t.timing.Connect += connect
+	t.timing.AppConnect += appConnect
+	t.timing.PreTransfer += preTransfer
+	t.timing.StartTransfer += startTransfer
+	t.timing.Total += total
+	if redirect {
+		t.timing.Redirect += total
+	}
+}
+
+// result returns the timing of every request ended so far
+func (t *requestTimer) result() requestTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.timing
+}
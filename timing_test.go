@@ -0,0 +1,130 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRequestTiming(t *testing.T) {
+	handler := http.NewServeMux()
+	handler.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		// 50ms until the first byte, then 30ms of transfer
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("first"))
+		w.(http.Flusher).Flush()
+		time.Sleep(30 * time.Millisecond)
+		w.Write([]byte("second"))
+	})
+	handler.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		http.Redirect(w, r, "/slow", http.StatusFound)
+	})
+
+	tlsServer := httptest.NewTLSServer(handler)
+	defer tlsServer.Close()
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		command  string
+		tls      bool
+		redirect bool
+	}{
+		{name: "HTTP", command: `curl ` + server.URL + `/slow`},
+		{name: "HTTPS", command: `curl -k ` + tlsServer.URL + `/slow`, tls: true},
+		{name: "Redirect", command: `curl -L ` + server.URL + `/redirect`, redirect: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb := &CurlBatch{}
+			defer cb.closeIdleConnections()
+
+			resp, err := cb.executeRequest(mustParseCurlCommand(t, tt.command))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			timing := resp.Timing
+
+			if tt.tls != (timing.AppConnect > 0) {
+				t.Errorf("Expected a TLS handshake time only for HTTPS, got %s", timing.AppConnect)
+			}
+			if timing.Connect < timing.NameLookup || timing.AppConnect > 0 && timing.AppConnect < timing.Connect ||
+				timing.PreTransfer < max(timing.Connect, timing.AppConnect) ||
+				timing.StartTransfer < timing.PreTransfer || timing.Total < timing.StartTransfer {
+				t.Errorf("Expected cumulative timing values, got %+v", timing)
+			}
+			if timing.StartTransfer < 50*time.Millisecond {
+				t.Errorf("Expected time to first byte of at least 50ms, got %s", timing.StartTransfer)
+			}
+			if timing.Total-timing.StartTransfer < 30*time.Millisecond {
+				t.Errorf("Expected a transfer time of at least 30ms, got %s", timing.Total-timing.StartTransfer)
+			}
+			if tt.redirect != (timing.Redirect >= 20*time.Millisecond) {
+				t.Errorf("Unexpected redirect time %s", timing.Redirect)
+			}
+			if timing.Total > resp.Duration {
+				t.Errorf("Expected total %s to be within the request duration %s", timing.Total, resp.Duration)
+			}
+		})
+	}
+}
+
+func TestRequestTimingReusedConnection(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	cb := &CurlBatch{}
+	defer cb.closeIdleConnections()
+
+	var timing requestTiming
+	for range 2 {
+		resp, err := cb.executeRequest(mustParseCurlCommand(t, `curl `+server.URL))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		timing = resp.Timing
+	}
+
+	if timing.NameLookup != 0 || timing.Connect != 0 {
+		t.Errorf("Expected no lookup and connect time on a reused connection, got %+v", timing)
+	}
+}
+
+func TestRequestTimingFormat(t *testing.T) {
+	timing := requestTiming{
+		NameLookup:    1 * time.Millisecond,
+		Connect:       3 * time.Millisecond,
+		AppConnect:    10 * time.Millisecond,
+		PreTransfer:   10500 * time.Microsecond,
+		StartTransfer: 40 * time.Millisecond,
+		Total:         42250 * time.Microsecond,
+	}
+
+	expected := "dns 1.000ms, connect 2.000ms, tls 7.000ms, ttfb 40.000ms, transfer 2.250ms, total 42.250ms"
+	if timing.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, timing.String())
+	}
+
+	r := &rowResult{Response: &httpResponse{Timing: timing}}
+	for column, want := range map[string]string{
+		"time_namelookup":    "0.001000",
+		"time_appconnect":    "0.010000",
+		"time_pretransfer":   "0.010500",
+		"time_starttransfer": "0.040000",
+		"time_redirect":      "0.000000",
+		"time_total":         "0.042250",
+	} {
+		if got := resultColumns[column](r); got != want {
+			t.Errorf("Expected %s %q, got %q", column, want, got)
+		}
+	}
+	if got := resultColumns["time_total"](&rowResult{}); got != "" {
+		t.Errorf("Expected an empty column without a response, got %q", got)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// tlsOptions holds the TLS related curl options of a request
+type tlsOptions struct {
+	Insecure   bool   // -k/--insecure
+	CACert     string // --cacert
+	CAPath     string // --capath
+	Cert       string // --cert
+	Key        string // --key
+	MinVersion uint16 // --tlsv1.2, --tlsv1.3
+}
+
+// parseResolve parses a --resolve entry, "host:port:address", and returns
+// the "host:port" it applies to and the address to connect to instead
+func parseResolve(value string) (string, string, error) {
+	value = strings.TrimPrefix(value, "+")
+	host, rest, found1 := strings.Cut(value, ":")
+	port, address, found2 := strings.Cut(rest, ":")
+	if !found1 || !found2 || host == "" || port == "" || address == "" {
+		return "", "", fmt.Errorf("invalid --resolve %q: expected host:port:address", value)
+	}
+
+	// curl accepts a list of addresses; the first one is used
+	address, _, _ = strings.Cut(address, ",")
+	address = strings.TrimSuffix(strings.TrimPrefix(address, "["), "]")
+	if net.ParseIP(address) == nil {
+		return "", "", fmt.Errorf("invalid --resolve %q: %q is not an IP address", value, address)
+	}
+
+	return strings.ToLower(host) + ":" + port, address, nil
+}
+
+// newTransport returns a transport configured with the pool options of the
+// batch and the TLS, --resolve, proxy and connect timeout options of the
+// request
+func (cb *CurlBatch) newTransport(creq *curlRequest) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	cb.Pool.apply(transport, cb.Concurrency)
+	setHTTPVersion(transport, creq.HTTPVersion)
+
+	if proxy := cb.proxyFunc(creq); proxy != nil {
+		transport.Proxy = proxy
+	}
+
+	if creq.TLS != (tlsOptions{}) {
+		tlsConfig, err := newTLSConfig(creq.TLS)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	connectTimeout, _ := cb.timeouts(creq)
+	if len(creq.Resolve) > 0 || connectTimeout > 0 {
+		transport.DialContext = newDialer(creq.Resolve, connectTimeout)
+	}
+	if connectTimeout > 0 {
+		transport.TLSHandshakeTimeout = connectTimeout
+	}
+
+	return transport, nil
+}
+
+// newTLSConfig maps the TLS options onto a tls.Config
+func newTLSConfig(opts tlsOptions) (*tls.Config, error) {
+	config := &tls.Config{
+		InsecureSkipVerify: opts.Insecure,
+		MinVersion:         opts.MinVersion,
+	}
+
+	// Like curl, --cacert and --capath replace the system certificates
+	if opts.CACert != "" || opts.CAPath != "" {
+		pool, err := loadCertPool(opts.CACert, opts.CAPath)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+
+	if opts.Cert != "" {
+		// Without --key the private key is read from the certificate file
+		key := opts.Key
+		if key == "" {
+			key = opts.Cert
+		}
+		cert, err := tls.LoadX509KeyPair(opts.Cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	} else if opts.Key != "" {
+		return nil, fmt.Errorf("--key requires --cert")
+	}
+
+	return config, nil
+}
+
+// loadCertPool reads the PEM certificates of a --cacert file and of every
+// file in a --capath directory
+func loadCertPool(caFile, caDir string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+	}
+
+	if caDir != "" {
+		entries, err := os.ReadDir(caDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA directory: %w", err)
+		}
+		found := false
+		for _, entry := range entries {
+			pem, err := os.ReadFile(filepath.Join(caDir, entry.Name()))
+			if err != nil {
+				continue
+			}
+			if pool.AppendCertsFromPEM(pem) {
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no certificates found in %s", caDir)
+		}
+	}
+
+	return pool, nil
+}
+
+// newDialer returns a DialContext function that gives up after the connect
+// timeout (30 seconds if zero) and connects to the addresses given with
+// --resolve instead of looking up the host. TLS still verifies the
+// certificate against the host name in the URL.
+func newDialer(resolve map[string]string, connectTimeout time.Duration) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if connectTimeout == 0 {
+		connectTimeout = 30 * time.Second
+	}
+	dialer := &net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if host, port, err := net.SplitHostPort(addr); err == nil {
+			if address, ok := resolve[strings.ToLower(host)+":"+port]; ok {
+				addr = net.JoinHostPort(address, port)
+			}
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeServerCertificate writes the certificate and key of a test TLS server
+// as PEM files and returns their paths
+func writeServerCertificate(t *testing.T, server *httptest.Server, dir string) (string, string) {
+	t.Helper()
+
+	cert := server.TLS.Certificates[0]
+	certFile := filepath.Join(dir, "cert.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+	if err := os.WriteFile(certFile, certPEM, 0644); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	keyFile := filepath.Join(dir, "key.pem")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+
+	return certFile, keyFile
+}
+
+func TestTLSOptions(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "curl_batch_test_*")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) > 0 {
+			w.Write([]byte("client certificate"))
+		}
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequestClientCert, MaxVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+
+	certFile, keyFile := writeServerCertificate(t, server, tempDir)
+	serverURL, _ := url.Parse(server.URL)
+	port := serverURL.Port()
+
+	capath := filepath.Join(tempDir, "certs")
+	if err := os.Mkdir(capath, 0755); err != nil {
+		t.Fatalf("Failed to create CA directory: %v", err)
+	}
+	certPEM, _ := os.ReadFile(certFile)
+	os.WriteFile(filepath.Join(capath, "server.pem"), certPEM, 0644)
+	os.WriteFile(filepath.Join(capath, "README"), []byte("not a certificate"), 0644)
+
+	tests := []struct {
+		name     string
+		options  string
+		url      string
+		expected string
+		errText  string
+	}{
+		{
+			name:    "Untrusted certificate",
+			url:     server.URL,
+			errText: "certificate",
+		},
+		{
+			name:    "Insecure",
+			options: "-k",
+			url:     server.URL,
+		},
+		{
+			name:    "CA certificate",
+			options: "--cacert " + certFile,
+			url:     server.URL,
+		},
+		{
+			name:    "CA directory",
+			options: "--capath " + capath,
+			url:     server.URL,
+		},
+		{
+			name:     "Client certificate and key",
+			options:  "-k --cert " + certFile + " --key " + keyFile,
+			url:      server.URL,
+			expected: "client certificate",
+		},
+		{
+			name:    "Minimum TLS version",
+			options: "-k --tlsv1.3",
+			url:     server.URL,
+			errText: "protocol version",
+		},
+		{
+			name:    "Resolve pins the host name to an address",
+			options: "--cacert " + certFile + " --resolve example.com:" + port + ":127.0.0.1",
+			url:     "https://example.com:" + port + "/",
+		},
+		{
+			name:    "Missing CA file",
+			options: "--cacert " + filepath.Join(tempDir, "missing.pem"),
+			url:     server.URL,
+			errText: "failed to read CA certificate",
+		},
+		{
+			name:    "Key without certificate",
+			options: "--key " + keyFile,
+			url:     server.URL,
+			errText: "--key requires --cert",
+		},
+	}
+
+	cb := &CurlBatch{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := mustParseCurlCommand(t, "curl "+tt.options+" "+tt.url)
+			resp, err := cb.executeRequest(req)
+
+			if tt.errText != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errText) {
+					t.Errorf("Expected error containing %q, got %v", tt.errText, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if string(resp.Body) != tt.expected {
+				t.Errorf("Expected body %q, got %q", tt.expected, resp.Body)
+			}
+		})
+	}
+}
+
+func TestParseResolve(t *testing.T) {
+	tests := []struct {
+		value    string
+		hostPort string
+		address  string
+		hasError bool
+	}{
+		{value: "API.example.com:443:10.0.0.1", hostPort: "api.example.com:443", address: "10.0.0.1"},
+		{value: "+example.com:8443:10.0.0.1,10.0.0.2", hostPort: "example.com:8443", address: "10.0.0.1"},
+		{value: "example.com:443:[::1]", hostPort: "example.com:443", address: "::1"},
+		{value: "example.com:443", hasError: true},
+		{value: "example.com:443:not-an-ip", hasError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			hostPort, address, err := parseResolve(tt.value)
+
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("Expected error but got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if hostPort != tt.hostPort || address != tt.address {
+				t.Errorf("Expected %s -> %s, got %s -> %s", tt.hostPort, tt.address, hostPort, address)
+			}
+		})
+	}
+}